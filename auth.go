@@ -0,0 +1,153 @@
+package dhcpv6
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"fmt"
+)
+
+const (
+	//Authentication protocols
+	AuthProtocolDelayed        = 2
+	AuthProtocolReconfigureKey = 3
+
+	//Authentication algorithms
+	AuthAlgorithmHmacMd5 = 1
+
+	//Replay detection methods
+	AuthRdmMonotonic = 0
+
+	//Types of the authentication information of the reconfigure key
+	//authentication protocol
+	AuthReconfigureKeyValue = 1
+	AuthReconfigureHmacMd5  = 2
+)
+
+// Length of an HMAC-MD5 digest, which is carried at the end of the
+// authentication information by both the delayed authentication and the
+// reconfigure key authentication protocols.
+const hmacMd5Len = md5.Size
+
+// authFields is a protocol, algorithm and RDM triple of an Authentication
+// option.
+type authFields struct {
+	protocol, algorithm, rdm byte
+}
+
+// authCombinations lists the triples defined by RFC 3315 (sections 21.4 and
+// 21.5), with the name of each protocol.
+var authCombinations = map[authFields]string{
+	{AuthProtocolDelayed, AuthAlgorithmHmacMd5, AuthRdmMonotonic}:        "delayed authentication",
+	{AuthProtocolReconfigureKey, AuthAlgorithmHmacMd5, AuthRdmMonotonic}: "reconfigure key authentication",
+}
+
+// ValidateFields checks that the protocol, algorithm and RDM of the option are
+// one of the combinations defined for DHCPv6, returning an error wrapping
+// ErrUnsupportedAuth that describes the offending field otherwise.
+func (o *AuthOption) ValidateFields() error {
+	if _, ok := authCombinations[authFields{o.Protocol, o.Algorithm, o.RDM}]; ok {
+		return nil
+	}
+	for f, name := range authCombinations {
+		if f.protocol != o.Protocol {
+			continue
+		}
+		if f.algorithm != o.Algorithm {
+			return fmt.Errorf("%w: algorithm %d is not defined for %s", ErrUnsupportedAuth, o.Algorithm, name)
+		}
+		return fmt.Errorf("%w: RDM %d is not defined for %s", ErrUnsupportedAuth, o.RDM, name)
+	}
+	return fmt.Errorf("%w: unknown protocol %d", ErrUnsupportedAuth, o.Protocol)
+}
+
+// authOption returns the first Authentication option of the message.
+func (d *DhcpMessage) authOption() *AuthOption {
+	for _, o := range d.Options {
+		if auth, ok := o.(*AuthOption); ok {
+			return auth
+		}
+	}
+	return nil
+}
+
+// authDigest computes the HMAC-MD5 of the message, with the digest field of
+// auth (which must be one of its options) set to zero.
+//
+// The fields of auth must be valid (see ValidateFields). With the reconfigure
+// key authentication protocol, the authentication information must hold an
+// HMAC-MD5 digest rather than the reconfigure key itself.
+func (d *DhcpMessage) authDigest(auth *AuthOption, key []byte) ([]byte, error) {
+	if err := auth.ValidateFields(); err != nil {
+		return nil, err
+	}
+	info := auth.AuthenticationInformation
+	if auth.Protocol == AuthProtocolReconfigureKey {
+		//RFC 3315 section 21.5.1, a type followed by a 16 octet value
+		if len(info) != 1+hmacMd5Len {
+			return nil, ErrInvalidData
+		}
+		if info[0] != AuthReconfigureHmacMd5 {
+			return nil, fmt.Errorf("%w: reconfigure key information of type %d carries no digest", ErrUnsupportedAuth, info[0])
+		}
+	}
+	if len(info) < hmacMd5Len {
+		return nil, ErrInvalidData
+	}
+	zeroed := *auth
+	zeroed.AuthenticationInformation = make([]byte, len(auth.AuthenticationInformation))
+	copy(zeroed.AuthenticationInformation, auth.AuthenticationInformation[:len(auth.AuthenticationInformation)-hmacMd5Len])
+
+	msg := &DhcpMessage{
+		MsgType:       d.MsgType,
+		TransactionId: d.TransactionId,
+		Options:       make([]Option, len(d.Options)),
+	}
+	for i, o := range d.Options {
+		if o == auth {
+			o = &zeroed
+		}
+		msg.Options[i] = o
+	}
+	data, err := msg.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(md5.New, key)
+	mac.Write(data)
+	return mac.Sum(nil), nil
+}
+
+// SignAuth computes the HMAC-MD5 of the message using key, and stores it in
+// the last 16 octets of the authentication information of its Authentication
+// option. The option must already be present, with room for the digest.
+func (d *DhcpMessage) SignAuth(key []byte) error {
+	auth := d.authOption()
+	if auth == nil {
+		return ErrInvalidData
+	}
+	digest, err := d.authDigest(auth, key)
+	if err != nil {
+		return err
+	}
+	copy(auth.AuthenticationInformation[len(auth.AuthenticationInformation)-hmacMd5Len:], digest)
+	return nil
+}
+
+// VerifyAuth reports whether the message carries an Authentication option
+// (present), and if so whether its HMAC-MD5 digest is valid for key (ok).
+//
+// An option with an unsupported protocol, algorithm or RDM, or one that
+// carries no digest (such as the reconfigure key delivered in a Reply),
+// results in an error wrapping ErrUnsupportedAuth.
+func (d *DhcpMessage) VerifyAuth(key []byte) (ok bool, present bool, err error) {
+	auth := d.authOption()
+	if auth == nil {
+		return false, false, nil
+	}
+	digest, err := d.authDigest(auth, key)
+	if err != nil {
+		return false, true, err
+	}
+	info := auth.AuthenticationInformation
+	return hmac.Equal(digest, info[len(info)-hmacMd5Len:]), true, nil
+}
@@ -0,0 +1,89 @@
+package dhcpv6
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDhcpMessage_VerifyAuth(t *testing.T) {
+	key := []byte("secret key")
+	d, err := MinimalMessage(TypeReply)
+	assert.NoError(t, err)
+
+	ok, present, err := d.VerifyAuth(key)
+	assert.NoError(t, err)
+	assert.False(t, present)
+	assert.False(t, ok)
+
+	d.Options = append(d.Options, &AuthOption{
+		Protocol:                  AuthProtocolReconfigureKey,
+		Algorithm:                 AuthAlgorithmHmacMd5,
+		RDM:                       AuthRdmMonotonic,
+		ReplayDetection:           [8]byte{0, 0, 0, 0, 0, 0, 0, 1},
+		AuthenticationInformation: append([]byte{2}, make([]byte, 16)...),
+	})
+	assert.NoError(t, d.SignAuth(key))
+
+	data, err := d.MarshalBinary()
+	assert.NoError(t, err)
+	decoded := new(DhcpMessage)
+	assert.NoError(t, decoded.UnmarshalBinary(data))
+
+	ok, present, err = decoded.VerifyAuth(key)
+	assert.NoError(t, err)
+	assert.True(t, present)
+	assert.True(t, ok)
+
+	ok, present, err = decoded.VerifyAuth([]byte("wrong key"))
+	assert.NoError(t, err)
+	assert.True(t, present)
+	assert.False(t, ok)
+
+	decoded.TransactionId[0]++
+	ok, _, _ = decoded.VerifyAuth(key)
+	assert.False(t, ok, "tampered message")
+
+	//a Reply delivering the reconfigure key carries no digest to verify
+	auth := d.Options[len(d.Options)-1].(*AuthOption)
+	auth.AuthenticationInformation[0] = AuthReconfigureKeyValue
+	ok, present, err = d.VerifyAuth(key)
+	assert.ErrorIs(t, err, ErrUnsupportedAuth)
+	assert.True(t, present)
+	assert.False(t, ok)
+	assert.ErrorIs(t, d.SignAuth(key), ErrUnsupportedAuth)
+
+	auth.AuthenticationInformation = auth.AuthenticationInformation[1:]
+	_, _, err = d.VerifyAuth(key)
+	assert.Equal(t, ErrInvalidData, err, "the type is missing")
+
+	auth.AuthenticationInformation = append([]byte{AuthReconfigureHmacMd5}, make([]byte, 16)...)
+	auth.RDM = 1
+	_, _, err = d.VerifyAuth(key)
+	assert.ErrorIs(t, err, ErrUnsupportedAuth)
+	auth.RDM = AuthRdmMonotonic
+	auth.Protocol = 9
+	_, _, err = d.VerifyAuth(key)
+	assert.ErrorIs(t, err, ErrUnsupportedAuth)
+}
+
+func TestAuthOption_ValidateFields(t *testing.T) {
+	valid := []AuthOption{
+		{Protocol: AuthProtocolDelayed, Algorithm: AuthAlgorithmHmacMd5, RDM: AuthRdmMonotonic},
+		{Protocol: AuthProtocolReconfigureKey, Algorithm: AuthAlgorithmHmacMd5, RDM: AuthRdmMonotonic},
+	}
+	for _, o := range valid {
+		assert.NoError(t, o.ValidateFields(), "protocol %d", o.Protocol)
+	}
+
+	invalid := map[string]AuthOption{
+		"unknown protocol 9": {Protocol: 9, Algorithm: AuthAlgorithmHmacMd5},
+		"algorithm 2":        {Protocol: AuthProtocolDelayed, Algorithm: 2},
+		"RDM 1":              {Protocol: AuthProtocolReconfigureKey, Algorithm: AuthAlgorithmHmacMd5, RDM: 1},
+		"unknown protocol 0": {},
+	}
+	for msg, o := range invalid {
+		err := o.ValidateFields()
+		assert.ErrorIs(t, err, ErrUnsupportedAuth)
+		assert.Contains(t, err.Error(), msg)
+	}
+}
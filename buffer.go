@@ -0,0 +1,145 @@
+package dhcpv6
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// BufferMarshaler marshals messages into a single internal buffer which is
+// reused across calls, avoiding a fresh allocation for every message in tight
+// server loops.
+//
+// The returned data is only valid until the next call to Marshal. A
+// BufferMarshaler is not safe for concurrent use.
+type BufferMarshaler struct {
+	buf []byte
+}
+
+// Marshal encodes d exactly like d.MarshalBinary, but into the reused buffer.
+func (m *BufferMarshaler) Marshal(d *DhcpMessage) ([]byte, error) {
+	data := append(m.buf[:0], byte(d.MsgType))
+	data = append(data, d.TransactionId[:]...)
+	for _, v := range d.Options {
+		var err error
+		data, err = appendOption(data, v)
+		if err != nil {
+			return nil, err
+		}
+	}
+	m.buf = data
+	return data, nil
+}
+
+// binaryAppender is implemented by options that can encode themselves onto the
+// end of an existing slice, saving the allocation made by MarshalBinary. The
+// options with a simple layout implement it (such as the Client Identifier,
+// Elapsed Time and Status Code options), while those carrying sub-options do
+// not.
+type binaryAppender interface {
+	AppendBinary(data []byte) ([]byte, error)
+}
+
+// appendOption appends the wire-format of o to data, using AppendBinary where
+// o implements it. This is how every message encodes its options, so a Domain
+// Search List too long for a single option is split across several here.
+func appendOption(data []byte, o Option) ([]byte, error) {
+	if list, ok := o.(*DomainSearchListOption); ok {
+		return list.appendSplit(data)
+	}
+	if a, ok := o.(binaryAppender); ok {
+		return a.AppendBinary(data)
+	}
+	optionData, err := o.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return append(data, optionData...), nil
+}
+
+// WriteOption writes the wire-format of o to buf. Options implementing
+// AppendBinary are encoded directly into the free space of buf, without an
+// allocation of their own once buf has grown large enough.
+//
+// Nothing is written if o can not be marshaled.
+func WriteOption(buf *bytes.Buffer, o Option) error {
+	data, err := appendOption(buf.AvailableBuffer(), o)
+	if err != nil {
+		return err
+	}
+	buf.Write(data)
+	return nil
+}
+
+// WriteMessage writes the wire-format of d to buf, encoding each option with
+// WriteOption.
+//
+// Nothing is written if d can not be marshaled.
+func WriteMessage(buf *bytes.Buffer, d *DhcpMessage) error {
+	start := buf.Len()
+	buf.WriteByte(byte(d.MsgType))
+	buf.Write(d.TransactionId[:])
+	for _, o := range d.Options {
+		if err := WriteOption(buf, o); err != nil {
+			buf.Truncate(start)
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteTo writes the wire-format of d to w with a single call to w.Write, so w
+// may be a packet connection such as a *net.UDPConn, where each call sends a
+// datagram. It implements io.WriterTo.
+//
+// The message is assembled in a buffer that is reused across calls, rather
+// than allocated anew as by MarshalBinary. Nothing is written if an option can
+// not be marshaled.
+func (d *DhcpMessage) WriteTo(w io.Writer) (int64, error) {
+	var header [4]byte
+	header[0] = byte(d.MsgType)
+	copy(header[1:], d.TransactionId[:])
+	return writeMessageTo(w, header[:], d.Options)
+}
+
+// WriteTo writes the wire-format of d to w, like DhcpMessage.WriteTo.
+func (d *DhcpRelayMessage) WriteTo(w io.Writer) (int64, error) {
+	if err := checkIpv6Address(d.LinkAddress); err != nil {
+		return 0, err
+	}
+	if err := checkIpv6Address(d.PeerAddress); err != nil {
+		return 0, err
+	}
+	var header [34]byte
+	header[0] = byte(d.MsgType)
+	header[1] = d.HopCount
+	copy(header[2:], d.LinkAddress)
+	copy(header[18:], d.PeerAddress)
+	return writeMessageTo(w, header[:], d.Options)
+}
+
+// writeBuffers holds the buffers in which WriteTo assembles messages.
+var writeBuffers = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, 1500)
+		return &buf
+	},
+}
+
+// writeMessageTo assembles a message from its header and options in a buffer
+// taken from writeBuffers, and writes it to w at once.
+func writeMessageTo(w io.Writer, header []byte, options []Option) (int64, error) {
+	buf := writeBuffers.Get().(*[]byte)
+	defer writeBuffers.Put(buf)
+	data := append((*buf)[:0], header...)
+	for _, o := range options {
+		var err error
+		data, err = appendOption(data, o)
+		if err != nil {
+			return 0, err
+		}
+	}
+	*buf = data
+	n, err := w.Write(data)
+	return int64(n), err
+}
@@ -0,0 +1,162 @@
+package dhcpv6
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+func TestBufferMarshaler_Marshal(t *testing.T) {
+	m := new(BufferMarshaler)
+	for _, d := range []*DhcpMessage{largeReply(), exampleSolicit(), largeReply()} {
+		expected, err := d.MarshalBinary()
+		assert.NoError(t, err)
+		actual, err := m.Marshal(d)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	}
+}
+
+func BenchmarkBufferMarshaler_Marshal(b *testing.B) {
+	m := new(BufferMarshaler)
+	d := largeReply()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m.Marshal(d)
+	}
+}
+
+func TestWriteOption(t *testing.T) {
+	buf := bytes.NewBufferString("prefix")
+	options := []Option{
+		&UnknownOption{OptionCode: 1000, OptionData: []byte{0x01, 0x02}},
+		&ElapsedTimeOption{ElapsedTime: 100},
+	}
+	expected := []byte("prefix")
+	for _, o := range options {
+		data, err := o.MarshalBinary()
+		assert.NoError(t, err)
+		expected = append(expected, data...)
+		assert.NoError(t, WriteOption(buf, o))
+	}
+	assert.Equal(t, expected, buf.Bytes())
+
+	err := WriteOption(buf, &UnknownOption{OptionData: make([]byte, 65536)})
+	assert.Equal(t, ErrWontFit, err)
+	assert.Equal(t, expected, buf.Bytes())
+
+	//once the buffer has grown, options implementing AppendBinary need no
+	//allocation of their own
+	o := &ElapsedTimeOption{ElapsedTime: 100}
+	buf.Grow(64)
+	allocs := testing.AllocsPerRun(10, func() {
+		buf.Truncate(len(expected))
+		WriteOption(buf, o)
+	})
+	assert.Equal(t, float64(0), allocs)
+}
+
+func TestAppendBinary(t *testing.T) {
+	//AppendBinary must append exactly what MarshalBinary returns
+	options := []Option{
+		&ClientIdOption{Duid: placeholderClientDuid()},
+		&ServerIdOption{Duid: placeholderServerDuid()},
+		&OroOption{RequestedOptionCodes: []uint16{23, 24}},
+		&PreferenceOption{PreferenceValue: 255},
+		&ElapsedTimeOption{ElapsedTime: 100},
+		&StatusCodeOption{StatusCode: 2, StatusMessage: "no addresses"},
+		&RapidCommitOption{},
+		&DnsServersOption{Servers: []net.IP{net.ParseIP("2001:db8::53")}},
+		&InformationRefreshTimeOption{RefreshTime: 3600},
+		&UnknownOption{OptionCode: 1000, OptionData: []byte{0x01}},
+	}
+	for _, o := range options {
+		a, ok := o.(binaryAppender)
+		if !assert.True(t, ok, "%T", o) {
+			continue
+		}
+		expected, err := o.MarshalBinary()
+		assert.NoError(t, err)
+		data, err := a.AppendBinary([]byte("prefix"))
+		assert.NoError(t, err)
+		assert.Equal(t, append([]byte("prefix"), expected...), data, "%T", o)
+	}
+}
+
+func TestWriteMessage(t *testing.T) {
+	buf := new(bytes.Buffer)
+	for _, d := range []*DhcpMessage{largeReply(), exampleSolicit()} {
+		d.Options = append(d.Options, &UnknownOption{OptionCode: 1000, OptionData: []byte{0xff}})
+		expected, err := d.MarshalBinary()
+		assert.NoError(t, err)
+		buf.Reset()
+		assert.NoError(t, WriteMessage(buf, d))
+		assert.Equal(t, expected, buf.Bytes())
+	}
+
+	d := exampleSolicit()
+	d.Options = append(d.Options, new(IaAddrOption))
+	buf.Reset()
+	buf.WriteString("prefix")
+	assert.Equal(t, ErrIpv6AddressNotSet, WriteMessage(buf, d))
+	assert.Equal(t, "prefix", buf.String(), "nothing is written on failure")
+}
+
+// countingWriter records each call to Write, as a packet connection would send
+// each as a datagram of its own.
+type countingWriter struct {
+	writes [][]byte
+}
+
+func (w *countingWriter) Write(data []byte) (int, error) {
+	w.writes = append(w.writes, append([]byte{}, data...))
+	return len(data), nil
+}
+
+func TestDhcpMessage_WriteTo(t *testing.T) {
+	for _, d := range []*DhcpMessage{largeReply(), exampleSolicit()} {
+		expected, err := d.MarshalBinary()
+		assert.NoError(t, err)
+		w := new(countingWriter)
+		n, err := d.WriteTo(w)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(len(expected)), n)
+		assert.Equal(t, [][]byte{expected}, w.writes, "written at once")
+	}
+
+	d := exampleSolicit()
+	d.Options = append(d.Options, new(IaAddrOption))
+	w := new(countingWriter)
+	_, err := d.WriteTo(w)
+	assert.Equal(t, ErrIpv6AddressNotSet, err)
+	assert.Empty(t, w.writes, "nothing is written on failure")
+}
+
+func TestDhcpRelayMessage_WriteTo(t *testing.T) {
+	d := goldenRelayMessages(t)["relay_forward"]
+	expected, err := d.MarshalBinary()
+	assert.NoError(t, err)
+	w := new(countingWriter)
+	n, err := d.WriteTo(w)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(expected)), n)
+	assert.Equal(t, [][]byte{expected}, w.writes, "written at once")
+
+	d.LinkAddress = nil
+	_, err = d.WriteTo(new(bytes.Buffer))
+	assert.Equal(t, ErrIpv6AddressNotSet, err)
+}
+
+func BenchmarkDhcpMessage_WriteTo(b *testing.B) {
+	for name, d := range map[string]*DhcpMessage{"example": exampleSolicit(), "large": largeReply()} {
+		b.Run(name, func(b *testing.B) {
+			buf := new(bytes.Buffer)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				buf.Reset()
+				d.WriteTo(buf)
+			}
+		})
+	}
+}
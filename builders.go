@@ -0,0 +1,120 @@
+package dhcpv6
+
+// placeholderClientDuid and placeholderServerDuid return the DUIDs used by
+// MinimalMessage, based on the MAC addresses reserved for documentation (RFC
+// 7042). A new DUID is returned by each call, so that changes made to one
+// message do not carry over to others.
+func placeholderClientDuid() *LlDuid {
+	return &LlDuid{HardwareType: 1, LlAddress: []byte{0x00, 0x00, 0x5e, 0x00, 0x53, 0x01}}
+}
+func placeholderServerDuid() *LlDuid {
+	return &LlDuid{HardwareType: 1, LlAddress: []byte{0x00, 0x00, 0x5e, 0x00, 0x53, 0x02}}
+}
+
+// MinimalMessage returns the smallest valid message of type t, carrying only
+// the options required by RFC 3315 (with placeholder DUIDs where needed).
+// It is intended as a template and as a seed for tests.
+//
+// Relay and leasequery messages are not supported, and result in
+// ErrInvalidType.
+func MinimalMessage(t DhcpMessageType) (*DhcpMessage, error) {
+	clientId := &ClientIdOption{Duid: placeholderClientDuid()}
+	serverId := &ServerIdOption{Duid: placeholderServerDuid()}
+	elapsed := &ElapsedTimeOption{}
+	ia := &IaNaOption{}
+
+	d := &DhcpMessage{MsgType: t}
+	switch t {
+	case TypeSolicit, TypeRebind:
+		d.Options = []Option{clientId, elapsed}
+	case TypeAdvertise, TypeReply:
+		d.Options = []Option{clientId, serverId}
+	case TypeRequest, TypeRenew:
+		d.Options = []Option{clientId, serverId, elapsed}
+	case TypeConfirm:
+		d.Options = []Option{clientId, elapsed, ia}
+	case TypeRelease, TypeDecline:
+		d.Options = []Option{clientId, serverId, elapsed, ia}
+	case TypeReconfigure:
+		d.Options = []Option{
+			clientId,
+			serverId,
+			&ReconfMsgOption{MsgType: byte(TypeRenew)},
+			&AuthOption{Protocol: 3, Algorithm: 1},
+		}
+	case TypeInformationRequest:
+		d.Options = []Option{elapsed}
+	default:
+		return nil, ErrInvalidType
+	}
+	return d, nil
+}
+
+// newClientMessage builds a message of type t with a random transaction id,
+// carrying the Client Identifier (if clientDuid is set), Server Identifier (if
+// serverDuid is set) and an Elapsed Time of zero, followed by opts.
+func newClientMessage(t DhcpMessageType, clientDuid, serverDuid Duid, opts []Option) (*DhcpMessage, error) {
+	id, err := NewTransactionId()
+	if err != nil {
+		return nil, err
+	}
+	d := &DhcpMessage{
+		MsgType:       t,
+		TransactionId: id,
+		Options:       make([]Option, 0, 3+len(opts)),
+	}
+	if clientDuid != nil {
+		d.Options = append(d.Options, &ClientIdOption{Duid: clientDuid})
+	}
+	if serverDuid != nil {
+		d.Options = append(d.Options, &ServerIdOption{Duid: serverDuid})
+	}
+	d.Options = append(d.Options, &ElapsedTimeOption{})
+	d.Options = append(d.Options, opts...)
+	return d, nil
+}
+
+// NewSolicit creates a Solicit message with a random transaction id, carrying
+// the Client Identifier and Elapsed Time options followed by opts (such as
+// IA_NA or Option Request options).
+func NewSolicit(clientDuid Duid, opts ...Option) (*DhcpMessage, error) {
+	if clientDuid == nil {
+		return nil, ErrInvalidData
+	}
+	return newClientMessage(TypeSolicit, clientDuid, nil, opts)
+}
+
+// NewRequest creates a Request message for the server identified by
+// serverDuid (as learned from its Advertise), with a random transaction id.
+func NewRequest(clientDuid, serverDuid Duid, opts ...Option) (*DhcpMessage, error) {
+	if clientDuid == nil || serverDuid == nil {
+		return nil, ErrInvalidData
+	}
+	return newClientMessage(TypeRequest, clientDuid, serverDuid, opts)
+}
+
+// NewRenew creates a Renew message for the server that assigned the leases,
+// with a random transaction id. The IAs being renewed are passed in opts.
+func NewRenew(clientDuid, serverDuid Duid, opts ...Option) (*DhcpMessage, error) {
+	if clientDuid == nil || serverDuid == nil {
+		return nil, ErrInvalidData
+	}
+	return newClientMessage(TypeRenew, clientDuid, serverDuid, opts)
+}
+
+// NewRelease creates a Release message for the server that assigned the
+// leases, with a random transaction id. The IAs being released are passed in
+// opts.
+func NewRelease(clientDuid, serverDuid Duid, opts ...Option) (*DhcpMessage, error) {
+	if clientDuid == nil || serverDuid == nil {
+		return nil, ErrInvalidData
+	}
+	return newClientMessage(TypeRelease, clientDuid, serverDuid, opts)
+}
+
+// NewInformationRequest creates an Information-request message with a random
+// transaction id. The Client Identifier option is left out when clientDuid is
+// nil, which RFC 3315 permits for clients that wish to remain anonymous.
+func NewInformationRequest(clientDuid Duid, opts ...Option) (*DhcpMessage, error) {
+	return newClientMessage(TypeInformationRequest, clientDuid, nil, opts)
+}
@@ -0,0 +1,92 @@
+package dhcpv6
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMinimalMessage(t *testing.T) {
+	for msgType := TypeSolicit; msgType <= TypeInformationRequest; msgType++ {
+		d, err := MinimalMessage(msgType)
+		if !assert.NoError(t, err, "type %d", msgType) {
+			continue
+		}
+		assert.Equal(t, msgType, d.MsgType)
+		assert.NoError(t, d.Validate(), "type %d", msgType)
+
+		data, err := d.MarshalBinary()
+		assert.NoError(t, err)
+		_, err = DecodeConfig{Strict: true}.DecodeMessage(data)
+		assert.NoError(t, err, "type %d", msgType)
+	}
+
+	_, err := MinimalMessage(TypeRelayForward)
+	assert.Equal(t, ErrInvalidType, err)
+
+	//changing the DUIDs of one message leaves the next untouched
+	d, err := MinimalMessage(TypeRequest)
+	assert.NoError(t, err)
+	clientId, _ := d.ClientId()
+	clientId.Duid.(*LlDuid).LlAddress[5] = 0xff
+	serverId, _ := d.ServerId()
+	serverId.Duid.(*LlDuid).HardwareType = 6
+	d, err = MinimalMessage(TypeRequest)
+	assert.NoError(t, err)
+	clientId, _ = d.ClientId()
+	assert.Equal(t, placeholderClientDuid(), clientId.Duid)
+	serverId, _ = d.ServerId()
+	assert.Equal(t, placeholderServerDuid(), serverId.Duid)
+}
+
+func TestNewSolicit(t *testing.T) {
+	ia := &IaNaOption{IAID: [4]byte{0, 0, 0, 1}}
+	d, err := NewSolicit(placeholderClientDuid(), ia)
+	assert.NoError(t, err)
+	assert.Equal(t, TypeSolicit, d.MsgType)
+	assert.Equal(t, []Option{
+		&ClientIdOption{Duid: placeholderClientDuid()},
+		&ElapsedTimeOption{},
+		ia,
+	}, d.Options)
+	data, err := d.MarshalBinary()
+	assert.NoError(t, err)
+	_, err = DecodeConfig{Strict: true}.DecodeMessage(data)
+	assert.NoError(t, err)
+
+	_, err = NewSolicit(nil)
+	assert.Equal(t, ErrInvalidData, err)
+}
+
+func TestNewRequest(t *testing.T) {
+	constructors := map[DhcpMessageType]func(Duid, Duid, ...Option) (*DhcpMessage, error){
+		TypeRequest: NewRequest,
+		TypeRenew:   NewRenew,
+		TypeRelease: NewRelease,
+	}
+	for msgType, constructor := range constructors {
+		ia := &IaNaOption{IAID: [4]byte{0, 0, 0, 1}}
+		d, err := constructor(placeholderClientDuid(), placeholderServerDuid(), ia)
+		assert.NoError(t, err)
+		assert.Equal(t, msgType, d.MsgType)
+		assert.Equal(t, []Option{
+			&ClientIdOption{Duid: placeholderClientDuid()},
+			&ServerIdOption{Duid: placeholderServerDuid()},
+			&ElapsedTimeOption{},
+			ia,
+		}, d.Options)
+
+		_, err = constructor(placeholderClientDuid(), nil)
+		assert.Equal(t, ErrInvalidData, err)
+	}
+}
+
+func TestNewInformationRequest(t *testing.T) {
+	d, err := NewInformationRequest(placeholderClientDuid())
+	assert.NoError(t, err)
+	assert.Equal(t, TypeInformationRequest, d.MsgType)
+	assert.Equal(t, []Option{&ClientIdOption{Duid: placeholderClientDuid()}, &ElapsedTimeOption{}}, d.Options)
+
+	d, err = NewInformationRequest(nil, &OroOption{RequestedOptionCodes: []uint16{23}})
+	assert.NoError(t, err)
+	assert.Equal(t, []Option{&ElapsedTimeOption{}, &OroOption{RequestedOptionCodes: []uint16{23}}}, d.Options)
+}
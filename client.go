@@ -0,0 +1,477 @@
+package dhcpv6
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"time"
+)
+
+const (
+	//Information Refresh Time (RFC 4242)
+	IrtDefault = 86400 * time.Second
+	IrtMinimum = 600 * time.Second
+)
+
+// Headers that precede a message on the wire: IPv6 (40 octets) and UDP (8
+// octets).
+const udp6Overhead = 40 + 8
+
+// Client exchanges messages with DHCPv6 servers on behalf of a client.
+//
+// A Client created by NewClient sends to the All_DHCP_Relay_Agents_and_Servers
+// address and matches responses to requests by transaction id, retransmitting
+// as described in RFC 3315 section 14. Only one exchange may be in progress at
+// a time.
+type Client struct {
+	conn   net.PacketConn
+	server net.Addr
+}
+
+// interfaceAddrs returns the addresses of iface. It may be replaced in tests.
+var interfaceAddrs = (*net.Interface).Addrs
+
+// clientAddr returns the address a client on iface should bind to: the
+// link-local address of the interface, on PortClient.
+func clientAddr(iface *net.Interface) (*net.UDPAddr, error) {
+	addrs, err := interfaceAddrs(iface)
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.To4() != nil || !ipNet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		return &net.UDPAddr{IP: ipNet.IP, Port: PortClient, Zone: iface.Name}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNoLinkLocal, iface.Name)
+}
+
+// OpenClientConn opens a UDP connection bound to the link-local address of
+// iface on PortClient, as required of clients on hosts with more than one
+// interface. ErrNoLinkLocal is returned if iface has no link-local address
+// yet (e.g. while duplicate address detection is still in progress).
+func OpenClientConn(iface *net.Interface) (*net.UDPConn, error) {
+	addr, err := clientAddr(iface)
+	if err != nil {
+		return nil, err
+	}
+	return net.ListenUDP("udp6", addr)
+}
+
+// NewClient opens a connection on iface (see OpenClientConn) for exchanging
+// messages with the servers on its link. Responses are sent to the unicast
+// address of the client, so there is no need to join a multicast group.
+func NewClient(iface *net.Interface) (*Client, error) {
+	conn, err := OpenClientConn(iface)
+	if err != nil {
+		return nil, err
+	}
+	server := AllRelayAgentsAndServersAddr()
+	server.Zone = iface.Name
+	return &Client{conn: conn, server: server}, nil
+}
+
+// Close closes the connection of the client.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// retransmission holds the parameters of the retransmission algorithm (RFC
+// 3315 section 14) for one type of message. A zero mrt, mrc or mrd leaves
+// the corresponding limit out.
+type retransmission struct {
+	maxDelay time.Duration // the first transmission is delayed by up to maxDelay
+	irt      time.Duration // initial retransmission time
+	mrt      time.Duration // maximum retransmission time
+	mrc      int           // maximum retransmission count
+	mrd      time.Duration // maximum retransmission duration
+
+	// positiveFirst requires the first retransmission time to be greater
+	// than irt, as for Solicit messages.
+	positiveFirst bool
+}
+
+// Transmission and retransmission parameters (RFC 3315 section 5.5, with
+// SOL_MAX_RT as updated by RFC 7083).
+var solicitRetransmission = retransmission{
+	maxDelay:      time.Second,
+	irt:           time.Second,
+	mrt:           3600 * time.Second,
+	positiveFirst: true,
+}
+
+// randFactor returns the RAND factor of the retransmission algorithm,
+// uniformly distributed between -0.1 and 0.1.
+func randFactor() float64 {
+	return rand.Float64()*0.2 - 0.1
+}
+
+func (r retransmission) initial() time.Duration {
+	factor := randFactor()
+	if r.positiveFirst && factor <= 0 {
+		factor = -factor
+		if factor == 0 {
+			factor = 0.1
+		}
+	}
+	return r.irt + time.Duration(factor*float64(r.irt))
+}
+
+func (r retransmission) next(rt time.Duration) time.Duration {
+	rt = 2*rt + time.Duration(randFactor()*float64(rt))
+	if r.mrt != 0 && rt > r.mrt {
+		rt = r.mrt + time.Duration(randFactor()*float64(r.mrt))
+	}
+	return rt
+}
+
+// aLongTimeAgo is a read deadline that unblocks pending reads immediately.
+var aLongTimeAgo = time.Unix(1, 0)
+
+// exchange sends req, retransmitting it as described by r, and passes every
+// response to it to handle until handle returns true. When a retransmission
+// time passes without that happening, expired is called, ending the exchange
+// successfully if it returns true. Once r.mrc or r.mrd is reached without
+// success, ErrNoResponse is returned.
+//
+// The Elapsed Time option of req is updated for each transmission, and
+// cancelling ctx aborts the exchange with the error of ctx.
+func (c *Client) exchange(ctx context.Context, req *DhcpMessage, r retransmission, handle func(*DhcpMessage) bool, expired func() bool) error {
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			c.conn.SetReadDeadline(aLongTimeAgo)
+		case <-stop:
+		}
+	}()
+	defer func() {
+		close(stop)
+		<-stopped
+	}()
+
+	if r.maxDelay > 0 {
+		delay := time.NewTimer(time.Duration(rand.Int63n(int64(r.maxDelay))))
+		select {
+		case <-delay.C:
+		case <-ctx.Done():
+			delay.Stop()
+			return ctx.Err()
+		}
+	}
+
+	start := Now()
+	end := time.Now().Add(r.mrd)
+	buf := make([]byte, DefaultMaxMessageSize)
+	rt := r.initial()
+	for count := 1; ; count++ {
+		req.EnsureElapsedTime(start)
+		data, err := req.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		if _, err := c.conn.WriteTo(data, c.server); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+
+		deadline := time.Now().Add(rt)
+		if r.mrd != 0 && deadline.After(end) {
+			deadline = end
+		}
+		for {
+			if err := c.conn.SetReadDeadline(deadline); err != nil {
+				return err
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			n, _, err := c.conn.ReadFrom(buf)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			reply, err := DecodeConfig{}.DecodeMessage(buf[:n])
+			if err != nil || reply.ValidateReplyToStrict(req) != nil {
+				continue
+			}
+			if handle(reply) {
+				return nil
+			}
+		}
+
+		if expired() {
+			return nil
+		}
+		if (r.mrc != 0 && count >= r.mrc) || (r.mrd != 0 && !time.Now().Before(end)) {
+			return ErrNoResponse
+		}
+		rt = r.next(rt)
+	}
+}
+
+// Solicit sends req, which must be a Solicit, and returns the response chosen
+// as described in RFC 3315 section 17.1.2: a Reply (when rapid commit is in
+// use) or an Advertise with the maximum preference (255) is returned as soon
+// as it arrives. Otherwise, Advertise messages are collected until the first
+// retransmission time passes, and the most preferred is returned; if there
+// were none, the first to arrive after that is returned.
+//
+// Responses that do not match req (see ValidateReplyToStrict) or that carry
+// no Server Identifier are ignored. So are Advertise messages reporting
+// NoAddrsAvail (RFC 3315 section 17.1.3), and Reply messages unless both req
+// and the Reply carry a Rapid Commit option (section 17.1.4).
+//
+// The Solicit is retransmitted until a response arrives or ctx is done, and
+// its Elapsed Time option is kept up to date.
+func (c *Client) Solicit(ctx context.Context, req *DhcpMessage) (*DhcpMessage, error) {
+	if req.MsgType != TypeSolicit {
+		return nil, ErrInvalidType
+	}
+	rapidCommit := req.GetOption(OptionCodeRapidCommit) != nil
+	var best *DhcpMessage
+	var bestPref byte
+	collecting := true
+	handle := func(reply *DhcpMessage) bool {
+		if _, ok := reply.ServerId(); !ok {
+			return false
+		}
+		if reply.MsgType == TypeReply {
+			if !rapidCommit || reply.GetOption(OptionCodeRapidCommit) == nil {
+				return false
+			}
+			best = reply
+			return true
+		}
+		if status, ok := reply.StatusCode(); ok && status.StatusCode == NoAddrsAvail {
+			return false
+		}
+		var pref byte
+		if o, ok := reply.Preference(); ok {
+			pref = o.PreferenceValue
+		}
+		if best == nil || pref > bestPref {
+			best, bestPref = reply, pref
+		}
+		return bestPref == 255 || !collecting
+	}
+	expired := func() bool {
+		collecting = false
+		return best != nil
+	}
+	if err := c.exchange(ctx, req, solicitRetransmission, handle, expired); err != nil {
+		return nil, err
+	}
+	return best, nil
+}
+
+// CheckSize ensures msg can be sent on iface without being fragmented, by
+// comparing its encoded size against the interface MTU.
+func (c *Client) CheckSize(msg *DhcpMessage, iface *net.Interface) error {
+	data, err := msg.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if len(data)+udp6Overhead > iface.MTU {
+		return fmt.Errorf("%w: message is %d octets, but only %d fit in the MTU of %s",
+			ErrWontFit, len(data), iface.MTU-udp6Overhead, iface.Name)
+	}
+	return nil
+}
+
+// NextInformationRefresh returns when a stateless client should next refresh
+// its configuration, given the Reply it received at last.
+//
+// The Information Refresh Time option is honored when present (IrtDefault is
+// used otherwise), but never sooner than IrtMinimum.
+func NextInformationRefresh(reply *DhcpMessage, last time.Time) time.Time {
+	refresh := IrtDefault
+	for _, o := range reply.Options {
+		if irt, ok := o.(*InformationRefreshTimeOption); ok {
+			refresh = time.Duration(irt.RefreshTime) * time.Second
+			break
+		}
+	}
+	if refresh < IrtMinimum {
+		refresh = IrtMinimum
+	}
+	return last.Add(refresh)
+}
+
+// replyTypes lists the message types a server may respond with, for each
+// type of request.
+var replyTypes = map[DhcpMessageType][]DhcpMessageType{
+	TypeSolicit:            {TypeAdvertise, TypeReply},
+	TypeRequest:            {TypeReply},
+	TypeConfirm:            {TypeReply},
+	TypeRenew:              {TypeReply},
+	TypeRebind:             {TypeReply},
+	TypeRelease:            {TypeReply},
+	TypeDecline:            {TypeReply},
+	TypeInformationRequest: {TypeReply},
+	TypeLeasequery:         {TypeLeasequeryReply},
+}
+
+// ValidateReplyTo checks that reply is a response to req: the transaction ids
+// must match, and the reply must be of a type the server may send in response.
+func (reply *DhcpMessage) ValidateReplyTo(req *DhcpMessage) error {
+	if reply.TransactionId != req.TransactionId {
+		return ErrTransactionIdMismatch
+	}
+	for _, t := range replyTypes[req.MsgType] {
+		if t == reply.MsgType {
+			return nil
+		}
+	}
+	return ErrUnexpectedMessageType
+}
+
+// ValidateReplyToStrict behaves like ValidateReplyTo, additionally requiring
+// that the reply carries the same Client Id as the request (if it had one).
+func (reply *DhcpMessage) ValidateReplyToStrict(req *DhcpMessage) error {
+	err := reply.ValidateReplyTo(req)
+	if err != nil {
+		return err
+	}
+	reqId := findClientId(req.Options)
+	if reqId == nil {
+		return nil
+	}
+	replyId := findClientId(reply.Options)
+	if replyId == nil || !DuidEqual(reqId.Duid, replyId.Duid) {
+		return ErrClientIdMismatch
+	}
+	return nil
+}
+
+func findClientId(options []Option) *ClientIdOption {
+	for _, o := range options {
+		if id, ok := o.(*ClientIdOption); ok {
+			return id
+		}
+	}
+	return nil
+}
+
+// NetworkConfig is the stateless configuration a client typically applies
+// from a Reply.
+type NetworkConfig struct {
+	DnsServers   []net.IP
+	DomainSearch []string
+	NtpServers   []net.IP
+	Fqdn         string
+}
+
+// NetworkConfig collects the DNS servers, domain search list, NTP servers and
+// FQDN carried by the message. Repeated options are merged in the order they
+// appear; NTP servers given by name are not resolved and are left out.
+func (d *DhcpMessage) NetworkConfig() NetworkConfig {
+	var cfg NetworkConfig
+	for _, opt := range d.Options {
+		switch o := opt.(type) {
+		case *DnsServersOption:
+			cfg.DnsServers = append(cfg.DnsServers, o.Servers...)
+		case *DomainSearchListOption:
+			cfg.DomainSearch = append(cfg.DomainSearch, o.DomainNames...)
+		case *NtpServerOption:
+			for _, sub := range o.Suboptions {
+				if sub.Type == NtpSuboptionSrvAddr || sub.Type == NtpSuboptionMcAddr {
+					cfg.NtpServers = append(cfg.NtpServers, sub.Address)
+				}
+			}
+		case *FQDNOption:
+			cfg.Fqdn = o.DomainName
+		}
+	}
+	return cfg
+}
+
+// LeaseEntry is an address (or delegated prefix) assigned in an IA, as
+// listed by LeaseEntries.
+type LeaseEntry struct {
+	// IaType is the code of the IA the entry belongs to (OptionCodeIaNa,
+	// OptionCodeIaTa or OptionCodeIaPd).
+	IaType OptionCode
+	IAID   uint32
+
+	// Address is the assigned address, or the prefix for IA_PD entries.
+	Address      net.IP
+	PrefixLength uint8
+
+	PreferredLifetime uint32
+	ValidLifetime     uint32
+
+	// Status is the Status Code option nested in the address or prefix, or
+	// failing that in the IA. It is nil if neither carries one.
+	Status *StatusCodeOption
+}
+
+// LeaseEntries lists every address and delegated prefix carried by the IAs of
+// the message, in order. IAs without any addresses or prefixes (such as those
+// reporting NoAddrsAvail) do not produce entries.
+func (d *DhcpMessage) LeaseEntries() []LeaseEntry {
+	var entries []LeaseEntry
+	for _, o := range d.Options {
+		var iaid [4]byte
+		var options []Option
+		switch v := o.(type) {
+		case *IaNaOption:
+			iaid, options = v.IAID, v.IaNaOptions
+		case *IaTaOption:
+			iaid, options = v.IAID, v.IaTaOptions
+		case *IaPdOption:
+			iaid, options = v.IAID, v.IaPdOptions
+		default:
+			continue
+		}
+		iaStatus := findStatusCode(options)
+		for _, sub := range options {
+			entry := LeaseEntry{IaType: o.Code(), IAID: binary.BigEndian.Uint32(iaid[:])}
+			switch v := sub.(type) {
+			case *IaAddrOption:
+				entry.Address = v.Ipv6Address
+				entry.PrefixLength = 128
+				entry.PreferredLifetime = v.PreferredLifetime
+				entry.ValidLifetime = v.ValidLifetime
+				entry.Status = findStatusCode(v.IAddrOptions)
+			case *IaPrefixOption:
+				entry.Address = v.Ipv6Prefix
+				entry.PrefixLength = v.PrefixLength
+				entry.PreferredLifetime = v.PreferredLifetime
+				entry.ValidLifetime = v.ValidLifetime
+				entry.Status = findStatusCode(v.IaPrefixOptions)
+			default:
+				continue
+			}
+			if entry.Status == nil {
+				entry.Status = iaStatus
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func findStatusCode(options []Option) *StatusCodeOption {
+	for _, o := range options {
+		if status, ok := o.(*StatusCodeOption); ok {
+			return status
+		}
+	}
+	return nil
+}
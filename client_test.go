@@ -0,0 +1,400 @@
+package dhcpv6
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNextInformationRefresh(t *testing.T) {
+	last := time.Date(2015, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	reply := &DhcpMessage{MsgType: TypeReply}
+	assert.Equal(t, last.Add(24*time.Hour), NextInformationRefresh(reply, last), "default when absent")
+
+	reply.Options = []Option{&InformationRefreshTimeOption{RefreshTime: 3600}}
+	assert.Equal(t, last.Add(time.Hour), NextInformationRefresh(reply, last))
+
+	reply.Options = []Option{&InformationRefreshTimeOption{RefreshTime: 60}}
+	assert.Equal(t, last.Add(10*time.Minute), NextInformationRefresh(reply, last), "below the minimum")
+}
+
+func TestDhcpMessage_ValidateReplyToStrict(t *testing.T) {
+	clientId := &ClientIdOption{Duid: &LlDuid{HardwareType: 1, LlAddress: []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}}}
+	otherId := &ClientIdOption{Duid: &LlDuid{HardwareType: 1, LlAddress: []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x56}}}
+	req := &DhcpMessage{
+		MsgType:       TypeSolicit,
+		TransactionId: [3]byte{0x01, 0x02, 0x03},
+		Options:       []Option{clientId},
+	}
+
+	reply := &DhcpMessage{
+		MsgType:       TypeAdvertise,
+		TransactionId: [3]byte{0x01, 0x02, 0x03},
+		Options:       []Option{clientId},
+	}
+	assert.NoError(t, reply.ValidateReplyToStrict(req))
+
+	reply.TransactionId = [3]byte{0x01, 0x02, 0x04}
+	assert.Equal(t, ErrTransactionIdMismatch, reply.ValidateReplyToStrict(req))
+
+	reply.TransactionId = req.TransactionId
+	reply.MsgType = TypeReconfigure
+	assert.Equal(t, ErrUnexpectedMessageType, reply.ValidateReplyToStrict(req))
+
+	reply.MsgType = TypeReply
+	reply.Options = []Option{otherId}
+	assert.Equal(t, ErrClientIdMismatch, reply.ValidateReplyToStrict(req))
+	assert.NoError(t, reply.ValidateReplyTo(req), "client id is only checked in strict mode")
+
+	reply.Options = nil
+	assert.Equal(t, ErrClientIdMismatch, reply.ValidateReplyToStrict(req), "missing client id")
+}
+
+func TestClient_CheckSize(t *testing.T) {
+	c := new(Client)
+	d := exampleSolicit()
+	data, _ := d.MarshalBinary()
+
+	iface := &net.Interface{Name: "eth0", MTU: 1500}
+	assert.NoError(t, c.CheckSize(d, iface))
+
+	iface.MTU = len(data) + 48
+	assert.NoError(t, c.CheckSize(d, iface), "exactly fits")
+
+	iface.MTU--
+	err := c.CheckSize(d, iface)
+	assert.True(t, errors.Is(err, ErrWontFit))
+	assert.Contains(t, err.Error(), "eth0")
+}
+
+func TestDhcpMessage_NetworkConfig(t *testing.T) {
+	d := &DhcpMessage{
+		MsgType: TypeReply,
+		Options: []Option{
+			&DnsServersOption{Servers: []net.IP{net.ParseIP("2001:db8::53")}},
+			&DomainSearchListOption{DomainNames: []string{"example.com", "example.net"}},
+			&NtpServerOption{Suboptions: []NtpSuboption{
+				{Type: NtpSuboptionSrvAddr, Address: net.ParseIP("2001:db8::123")},
+				{Type: NtpSuboptionSrvFqdn, Fqdn: "ntp.example.com"},
+			}},
+			&FQDNOption{DomainName: "host.example.com"},
+		},
+	}
+	data, err := d.MarshalBinary()
+	assert.NoError(t, err)
+	decoded := new(DhcpMessage)
+	assert.NoError(t, decoded.UnmarshalBinary(data))
+
+	cfg := decoded.NetworkConfig()
+	assert.Equal(t, []net.IP{net.ParseIP("2001:db8::53")}, cfg.DnsServers)
+	assert.Equal(t, []string{"example.com", "example.net"}, cfg.DomainSearch)
+	assert.Equal(t, []net.IP{net.ParseIP("2001:db8::123")}, cfg.NtpServers)
+	assert.Equal(t, "host.example.com", cfg.Fqdn)
+
+	assert.Equal(t, NetworkConfig{}, (&DhcpMessage{MsgType: TypeReply}).NetworkConfig())
+}
+
+func TestDhcpMessage_LeaseEntries(t *testing.T) {
+	notOnLink := &StatusCodeOption{StatusCode: NotOnLink}
+	success := &StatusCodeOption{StatusCode: Success}
+	d := &DhcpMessage{
+		MsgType: TypeReply,
+		Options: []Option{
+			&IaNaOption{
+				IAID: [4]byte{0, 0, 0, 1},
+				IaNaOptions: []Option{
+					&IaAddrOption{Ipv6Address: net.ParseIP("2001:db8::1"), PreferredLifetime: 100, ValidLifetime: 200},
+					&IaAddrOption{Ipv6Address: net.ParseIP("2001:db8::2"), IAddrOptions: []Option{notOnLink}},
+					success,
+				},
+			},
+			&IaNaOption{IAID: [4]byte{0, 0, 0, 2}, IaNaOptions: []Option{&StatusCodeOption{StatusCode: NoAddrsAvail}}},
+			&IaPdOption{
+				IAID: [4]byte{0, 0, 0, 3},
+				IaPdOptions: []Option{
+					&IaPrefixOption{Ipv6Prefix: net.ParseIP("2001:db8:1::"), PrefixLength: 48, PreferredLifetime: 300, ValidLifetime: 400},
+				},
+			},
+		},
+	}
+	assert.Equal(t, []LeaseEntry{
+		{IaType: OptionCodeIaNa, IAID: 1, Address: net.ParseIP("2001:db8::1"), PrefixLength: 128, PreferredLifetime: 100, ValidLifetime: 200, Status: success},
+		{IaType: OptionCodeIaNa, IAID: 1, Address: net.ParseIP("2001:db8::2"), PrefixLength: 128, Status: notOnLink},
+		{IaType: OptionCodeIaPd, IAID: 3, Address: net.ParseIP("2001:db8:1::"), PrefixLength: 48, PreferredLifetime: 300, ValidLifetime: 400},
+	}, d.LeaseEntries())
+
+	assert.Empty(t, (&DhcpMessage{MsgType: TypeReply}).LeaseEntries())
+}
+
+func TestOpenClientConn(t *testing.T) {
+	defer func(f func(*net.Interface) ([]net.Addr, error)) { interfaceAddrs = f }(interfaceAddrs)
+	iface := &net.Interface{Name: "eth0"}
+
+	var addrs []net.Addr
+	interfaceAddrs = func(*net.Interface) ([]net.Addr, error) { return addrs, nil }
+	addrs = []net.Addr{
+		&net.IPNet{IP: net.ParseIP("192.0.2.1"), Mask: net.CIDRMask(24, 32)},
+		&net.IPNet{IP: net.ParseIP("2001:db8::1"), Mask: net.CIDRMask(64, 128)},
+	}
+	_, err := OpenClientConn(iface)
+	assert.True(t, errors.Is(err, ErrNoLinkLocal), "no link-local address yet")
+
+	addrs = append(addrs, &net.IPNet{IP: net.ParseIP("fe80::1"), Mask: net.CIDRMask(64, 128)})
+	addr, err := clientAddr(iface)
+	assert.NoError(t, err)
+	assert.Equal(t, &net.UDPAddr{IP: net.ParseIP("fe80::1"), Port: PortClient, Zone: "eth0"}, addr)
+}
+
+func TestNewClient(t *testing.T) {
+	defer func(f func(*net.Interface) ([]net.Addr, error)) { interfaceAddrs = f }(interfaceAddrs)
+	interfaceAddrs = func(*net.Interface) ([]net.Addr, error) { return nil, nil }
+	_, err := NewClient(&net.Interface{Name: "eth0"})
+	assert.True(t, errors.Is(err, ErrNoLinkLocal))
+
+	//binding PortClient needs an interface with a link-local address, and
+	//usually privileges, so this part only runs where both are available
+	interfaceAddrs = (*net.Interface).Addrs
+	ifaces, _ := net.Interfaces()
+	for _, iface := range ifaces {
+		if _, err := clientAddr(&iface); err != nil {
+			continue
+		}
+		c, err := NewClient(&iface)
+		if err != nil {
+			t.Skip(err)
+		}
+		defer c.Close()
+		assert.Equal(t, PortClient, c.conn.LocalAddr().(*net.UDPAddr).Port)
+		assert.Equal(t, &net.UDPAddr{
+			IP:   net.ParseIP(AddressAllDhcpRelayAgentsAndServers),
+			Port: PortServer,
+			Zone: iface.Name,
+		}, c.server)
+		return
+	}
+	t.Skip("no interface with a link-local address")
+}
+
+// testClient returns a Client and the connection of the server it sends to,
+// both on the loopback interface, with short retransmission times.
+func testClient(t *testing.T) (*Client, net.PacketConn) {
+	conn, err := net.ListenPacket("udp6", "[::1]:0")
+	assert.NoError(t, err)
+	server, err := net.ListenPacket("udp6", "[::1]:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		conn.Close()
+		server.Close()
+	})
+
+	r := solicitRetransmission
+	t.Cleanup(func() { solicitRetransmission = r })
+	solicitRetransmission = retransmission{irt: 20 * time.Millisecond, mrt: 80 * time.Millisecond, positiveFirst: true}
+	return &Client{conn: conn, server: server.LocalAddr()}, server
+}
+
+// serve answers each request received by server with the responses returned
+// by respond, which is given the number of requests received so far.
+func serve(server net.PacketConn, respond func(req *DhcpMessage, count int) []*DhcpMessage) {
+	buf := make([]byte, 1500)
+	for count := 1; ; count++ {
+		n, addr, err := server.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		req := new(DhcpMessage)
+		if req.UnmarshalBinary(buf[:n]) != nil {
+			continue
+		}
+		for _, resp := range respond(req, count) {
+			data, _ := resp.MarshalBinary()
+			server.WriteTo(data, addr)
+		}
+	}
+}
+
+// advertise returns an Advertise in response to req, from the server
+// identified by id.
+func advertise(req *DhcpMessage, id byte, pref byte) *DhcpMessage {
+	clientId, _ := req.ClientId()
+	return &DhcpMessage{
+		MsgType:       TypeAdvertise,
+		TransactionId: req.TransactionId,
+		Options: []Option{
+			clientId,
+			&ServerIdOption{Duid: &LlDuid{HardwareType: 1, LlAddress: []byte{0x00, 0x00, 0x5e, 0x00, 0x53, id}}},
+			&PreferenceOption{PreferenceValue: pref},
+		},
+	}
+}
+
+func TestClient_Solicit(t *testing.T) {
+	c, server := testClient(t)
+	elapsed := make(chan uint16, 10)
+	go serve(server, func(req *DhcpMessage, count int) []*DhcpMessage {
+		elapsed <- req.GetOption(OptionCodeElapsedTime).(*ElapsedTimeOption).ElapsedTime
+		if count < 2 {
+			return nil
+		}
+		other := advertise(req, 1, 0)
+		other.TransactionId[0]++
+		return []*DhcpMessage{other, advertise(req, 2, 0)}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	reply, err := c.Solicit(ctx, exampleSolicit())
+	assert.NoError(t, err)
+	assert.Equal(t, TypeAdvertise, reply.MsgType)
+	serverId, _ := reply.ServerId()
+	assert.Equal(t, byte(2), serverId.Duid.(*LlDuid).LlAddress[5], "mismatched transaction id is ignored")
+	assert.Len(t, elapsed, 2, "retransmitted once")
+	assert.Equal(t, uint16(0), <-elapsed)
+	assert.NotZero(t, <-elapsed, "elapsed time is updated")
+
+	_, err = c.Solicit(ctx, &DhcpMessage{MsgType: TypeRequest})
+	assert.Equal(t, ErrInvalidType, err)
+}
+
+func TestClient_Solicit_Preference(t *testing.T) {
+	c, server := testClient(t)
+	go serve(server, func(req *DhcpMessage, count int) []*DhcpMessage {
+		return []*DhcpMessage{advertise(req, 1, 1), advertise(req, 2, 5), advertise(req, 3, 2)}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	reply, err := c.Solicit(ctx, exampleSolicit())
+	assert.NoError(t, err)
+	pref, _ := reply.Preference()
+	assert.Equal(t, byte(5), pref.PreferenceValue, "most preferred within the first retransmission time")
+
+	//an Advertise with the maximum preference ends the exchange at once
+	c, server = testClient(t)
+	solicitRetransmission.irt = time.Hour
+	go serve(server, func(req *DhcpMessage, count int) []*DhcpMessage {
+		return []*DhcpMessage{advertise(req, 1, 255)}
+	})
+	reply, err = c.Solicit(ctx, exampleSolicit())
+	assert.NoError(t, err)
+	pref, _ = reply.Preference()
+	assert.Equal(t, byte(255), pref.PreferenceValue)
+}
+
+func TestClient_Solicit_RapidCommit(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	//a Reply ends the exchange at once, but only when both sides use rapid commit
+	for _, tc := range []struct {
+		name         string
+		req, reply   bool
+		expectedType DhcpMessageType
+	}{
+		{"both", true, true, TypeReply},
+		{"reply without rapid commit", true, false, TypeAdvertise},
+		{"solicit without rapid commit", false, true, TypeAdvertise},
+	} {
+		c, server := testClient(t)
+		go serve(server, func(req *DhcpMessage, count int) []*DhcpMessage {
+			reply := advertise(req, 1, 0)
+			reply.MsgType = TypeReply
+			reply.RemoveOptions(OptionCodePreference)
+			if tc.reply {
+				reply.AddOption(&RapidCommitOption{})
+			}
+			return []*DhcpMessage{reply, advertise(req, 2, 0)}
+		})
+		req := exampleSolicit()
+		if !tc.req {
+			req.RemoveOptions(OptionCodeRapidCommit)
+		}
+		reply, err := c.Solicit(ctx, req)
+		if assert.NoError(t, err, tc.name) {
+			assert.Equal(t, tc.expectedType, reply.MsgType, tc.name)
+		}
+	}
+}
+
+func TestClient_Solicit_NoAddrsAvail(t *testing.T) {
+	c, server := testClient(t)
+	go serve(server, func(req *DhcpMessage, count int) []*DhcpMessage {
+		unavailable := advertise(req, 1, 255)
+		unavailable.AddOption(&StatusCodeOption{StatusCode: NoAddrsAvail})
+		return []*DhcpMessage{unavailable, advertise(req, 2, 0)}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	reply, err := c.Solicit(ctx, exampleSolicit())
+	assert.NoError(t, err)
+	serverId, _ := reply.ServerId()
+	assert.Equal(t, byte(2), serverId.Duid.(*LlDuid).LlAddress[5], "NoAddrsAvail is ignored")
+}
+
+func TestClient_Solicit_MissingServerId(t *testing.T) {
+	c, server := testClient(t)
+	go serve(server, func(req *DhcpMessage, count int) []*DhcpMessage {
+		anonymous := advertise(req, 1, 255)
+		anonymous.RemoveOptions(OptionCodeServerId)
+		return []*DhcpMessage{anonymous, advertise(req, 2, 0)}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	reply, err := c.Solicit(ctx, exampleSolicit())
+	assert.NoError(t, err)
+	pref, _ := reply.Preference()
+	assert.Equal(t, byte(0), pref.PreferenceValue, "an Advertise without a Server Identifier is ignored")
+}
+
+func TestClient_Solicit_Cancel(t *testing.T) {
+	c, _ := testClient(t)
+	solicitRetransmission.irt = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := c.Solicit(ctx, exampleSolicit())
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestClient_exchange(t *testing.T) {
+	c, server := testClient(t)
+	requests := make(chan int, 10)
+	go serve(server, func(req *DhcpMessage, n int) []*DhcpMessage {
+		requests <- n
+		return nil
+	})
+
+	r := retransmission{irt: 10 * time.Millisecond, mrc: 3}
+	never := func(*DhcpMessage) bool { return false }
+	expired := func() bool { return false }
+	err := c.exchange(context.Background(), exampleSolicit(), r, never, expired)
+	assert.Equal(t, ErrNoResponse, err)
+	assert.Len(t, requests, 3)
+
+	r = retransmission{irt: 10 * time.Millisecond, mrd: 50 * time.Millisecond}
+	err = c.exchange(context.Background(), exampleSolicit(), r, never, expired)
+	assert.Equal(t, ErrNoResponse, err)
+}
+
+func TestRetransmission(t *testing.T) {
+	r := retransmission{irt: time.Second, mrt: 10 * time.Second, positiveFirst: true}
+	for i := 0; i < 100; i++ {
+		rt := r.initial()
+		assert.True(t, rt > r.irt && rt <= 1100*time.Millisecond, "initial %v", rt)
+		rt = r.next(rt)
+		assert.True(t, rt >= 1800*time.Millisecond && rt <= 2420*time.Millisecond, "next %v", rt)
+		rt = r.next(8 * time.Second)
+		assert.True(t, rt >= 9*time.Second && rt <= 11*time.Second, "capped %v", rt)
+	}
+	r.positiveFirst = false
+	for i := 0; i < 100; i++ {
+		rt := r.initial()
+		assert.True(t, rt >= 900*time.Millisecond && rt <= 1100*time.Millisecond, "initial %v", rt)
+	}
+}
@@ -0,0 +1,279 @@
+package dhcpv6
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// DecodeError is returned when a message could not be decoded, recording
+// which option failed and where. The underlying error (e.g. ErrUnexpectedEOF)
+// is available through Unwrap, so errors.Is may be used as usual.
+type DecodeError struct {
+	OptionCode OptionCode
+
+	// Offset of the failing option from the start of the message.
+	Offset int
+
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("Option %d at offset %d: %v", e.OptionCode, e.Offset, e.Err)
+}
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// anomalyReporter is implemented by options that tolerate certain malformed
+// input when decoding, describing what was tolerated.
+type anomalyReporter interface {
+	anomalies() []string
+}
+
+// checkAnomalies returns an error describing the first anomaly tolerated
+// while decoding options (or any option nested within them).
+func checkAnomalies(options []Option) error {
+	var err error
+	walkOptions(options, func(o Option) {
+		if r, ok := o.(anomalyReporter); ok && err == nil {
+			if a := r.anomalies(); len(a) > 0 {
+				err = fmt.Errorf("%w: %s", ErrInvalidData, a[0])
+			}
+		}
+	})
+	return err
+}
+
+// DecodeConfig controls how raw messages are decoded. The zero value is
+// lenient, and decodes exactly like the UnmarshalBinary methods.
+type DecodeConfig struct {
+	// Strict enables additional checks that reject messages which are
+	// well-formed, but do not follow the rules of the RFCs. Malformed input
+	// that is otherwise tolerated (such as legacy encodings) is rejected.
+	Strict bool
+
+	// Logger, if set, is called with a description of each anomaly that
+	// lenient decoding tolerated, to help debug interoperability problems.
+	// It is not used in strict mode, where anomalies are errors instead.
+	Logger func(format string, args ...interface{})
+
+	// MaxOptions limits the number of options in any one list: the
+	// top-level options of a message, those nested within an option such as
+	// an IA_NA, or those of a relayed message. It is checked before any
+	// option is decoded, so that a packet stuffed with options is rejected
+	// cheaply. The default (when 0) is DefaultMaxOptions, as applied by the
+	// UnmarshalBinary methods.
+	//
+	// A negative value removes the limit from the top-level options of the
+	// message. The lists nested within its options are decoded by the
+	// UnmarshalBinary methods of those options, and so are always limited to
+	// DefaultMaxOptions.
+	MaxOptions int
+
+	// MaxMessageSize limits the size of the input, which is rejected with
+	// ErrMessageTooLarge before any decoding if larger. The default (when 0)
+	// is DefaultMaxMessageSize.
+	MaxMessageSize int
+}
+
+// DefaultMaxMessageSize is the largest message accepted by a DecodeConfig
+// without a MaxMessageSize of its own: the most that fits in a UDP datagram,
+// or in the 2-octet length prefix of the TCP transport.
+const DefaultMaxMessageSize = 65535
+
+// DefaultMaxOptions is the number of options a list may hold when decoded by
+// the UnmarshalBinary methods, or by a DecodeConfig without a MaxOptions of its
+// own. Exceeding it results in ErrTooManyOptions.
+const DefaultMaxOptions = 256
+
+// checkSize rejects input larger than the configured maximum size.
+func (c DecodeConfig) checkSize(data []byte) error {
+	max := c.MaxMessageSize
+	if max == 0 {
+		max = DefaultMaxMessageSize
+	}
+	if len(data) > max {
+		return fmt.Errorf("%w: %d octets, at most %d expected", ErrMessageTooLarge, len(data), max)
+	}
+	return nil
+}
+
+// maxOptions returns the number of options permitted in a list, or 0 if there
+// is no limit.
+func (c DecodeConfig) maxOptions() int {
+	if c.MaxOptions == 0 {
+		return DefaultMaxOptions
+	}
+	if c.MaxOptions < 0 {
+		return 0
+	}
+	return c.MaxOptions
+}
+
+// checkOptionCount walks the options of a message starting at pos without
+// decoding them, returning ErrTooManyOptions (with the offset of the first
+// excess option) if any list holds more than permitted.
+func (c DecodeConfig) checkOptionCount(data []byte, pos int) error {
+	max := c.maxOptions()
+	if max == 0 {
+		return nil
+	}
+	return countOptions(data, pos, true, max)
+}
+
+// countOptions walks the list of options in data starting at pos, and each
+// list nested within them, returning ErrTooManyOptions if one holds more than
+// max. Offsets are reported from the start of data. Trailing zero padding is
+// not counted where the decoder ignores it (the options of a message), and
+// malformed options are left for the decoder to report.
+func countOptions(data []byte, pos int, padded bool, max int) error {
+	end := len(data)
+	if padded {
+		end = len(bytes.TrimRight(data, "\x00"))
+	}
+	for n := 0; pos < end && pos+4 <= len(data); n++ {
+		code := OptionCode(binary.BigEndian.Uint16(data[pos:]))
+		if n == max {
+			return &DecodeError{OptionCode: code, Offset: pos, Err: ErrTooManyOptions}
+		}
+		next := pos + 4 + int(binary.BigEndian.Uint16(data[pos+2:]))
+		if next > len(data) {
+			return nil
+		}
+		if offset, ok := nestedOptionsOffset(data[pos:next]); ok && pos+offset <= next {
+			err := countOptions(data[:next], pos+offset, code == OptionCodeRelayMsg, max)
+			if err != nil {
+				return err
+			}
+		}
+		pos = next
+	}
+	return nil
+}
+
+// nestedOptionsOffset returns where the options nested within the option in
+// data begin, for the option types that carry a list of options.
+func nestedOptionsOffset(data []byte) (int, bool) {
+	switch OptionCode(binary.BigEndian.Uint16(data)) {
+	case OptionCodeIaNa, OptionCodeIaPd:
+		return 16, true
+	case OptionCodeIaTa:
+		return 8, true
+	case OptionCodeIaAddr:
+		return 28, true
+	case OptionCodeIaPrefix:
+		return 29, true
+	case OptionCodeNextHop:
+		return 20, true
+	case OptionCodeS46ContMapE, OptionCodeS46ContMapT, OptionCodeS46ContLw:
+		return 4, true
+	case OptionCodeS46Rule:
+		if len(data) < 12 {
+			return 0, false
+		}
+		return 12 + (int(data[11])+7)/8, true
+	case OptionCodeRelayMsg:
+		if len(data) < 5 {
+			return 0, false
+		}
+		//the header of the relayed message
+		if t := DhcpMessageType(data[4]); t == TypeRelayForward || t == TypeRelayReply {
+			return 4 + 34, true
+		}
+		return 4 + 4, true
+	}
+	return 0, false
+}
+
+// logTolerated reports each anomaly tolerated while decoding to c.Logger.
+func (c DecodeConfig) logTolerated(options []Option, padding, length int) {
+	if c.Logger == nil {
+		return
+	}
+	walkOptions(options, func(o Option) {
+		if r, ok := o.(anomalyReporter); ok {
+			for _, a := range r.anomalies() {
+				c.Logger("Option %d: %s", o.Code(), a)
+			}
+		}
+	})
+	if padding > 0 {
+		c.Logger("Ignored %d bytes of padding at offset %d", padding, length-padding)
+	}
+}
+
+// DecodeMessage will decode a client/server message.
+//
+// In strict mode the message type must be one of KnownMessageTypes, and the
+// decoded message must also pass Validate. Zero padding following the options
+// (which is otherwise ignored) results in ErrTrailingData.
+func (c DecodeConfig) DecodeMessage(data []byte) (*DhcpMessage, error) {
+	if err := c.checkSize(data); err != nil {
+		return nil, err
+	}
+	if err := c.checkOptionCount(data, 4); err != nil {
+		return nil, err
+	}
+	d := new(DhcpMessage)
+	padding, err := d.unmarshalBinary(data, c.maxOptions())
+	if err != nil {
+		return nil, err
+	}
+	if c.Strict {
+		if padding > 0 {
+			return nil, &DecodeError{Offset: len(data) - padding, Err: ErrTrailingData}
+		}
+		if !isKnownMessageType(d.MsgType) {
+			return nil, ErrInvalidType
+		}
+		err = checkAnomalies(d.Options)
+		if err != nil {
+			return nil, err
+		}
+		err = d.Validate()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		c.logTolerated(d.Options, padding, len(data))
+	}
+	return d, nil
+}
+
+// DecodeRelayMessage will decode a relay agent/server message.
+//
+// In strict mode the message must be a Relay-Forward or Relay-Reply message,
+// and must carry exactly one Relay Message option. As for DecodeMessage, zero
+// padding results in ErrTrailingData.
+func (c DecodeConfig) DecodeRelayMessage(data []byte) (*DhcpRelayMessage, error) {
+	if err := c.checkSize(data); err != nil {
+		return nil, err
+	}
+	if err := c.checkOptionCount(data, 34); err != nil {
+		return nil, err
+	}
+	d := new(DhcpRelayMessage)
+	padding, err := d.unmarshalBinary(data, c.maxOptions())
+	if err != nil {
+		return nil, err
+	}
+	if c.Strict {
+		if padding > 0 {
+			return nil, &DecodeError{Offset: len(data) - padding, Err: ErrTrailingData}
+		}
+		if d.MsgType != TypeRelayForward && d.MsgType != TypeRelayReply {
+			return nil, ErrInvalidType
+		}
+		err = checkAnomalies(d.Options)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := d.relayMsgOption(); err != nil {
+			return nil, err
+		}
+	} else {
+		c.logTolerated(d.Options, padding, len(data))
+	}
+	return d, nil
+}
@@ -0,0 +1,286 @@
+package dhcpv6
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDecodeConfig_DecodeRelayMessage(t *testing.T) {
+	data := make([]byte, 34)
+	data[0] = byte(TypeRelayForward)
+
+	d, err := DecodeConfig{}.DecodeRelayMessage(data)
+	assert.NoError(t, err, "lenient mode accepts a header-only relay message")
+	assert.Empty(t, d.Options)
+
+	_, err = DecodeConfig{Strict: true}.DecodeRelayMessage(data)
+	assert.Equal(t, ErrMissingRelayMsg, err)
+
+	data = append(data, 0x00, 0x09, 0x00, 0x04, byte(TypeSolicit), 0x01, 0x02, 0x03)
+	d, err = DecodeConfig{Strict: true}.DecodeRelayMessage(data)
+	assert.NoError(t, err)
+	assert.Len(t, d.Options, 1)
+
+	data = append(data, 0x00, 0x09, 0x00, 0x04, byte(TypeRequest), 0x04, 0x05, 0x06)
+	_, err = DecodeConfig{Strict: true}.DecodeRelayMessage(data)
+	assert.True(t, errors.Is(err, ErrDuplicateOption), "two Relay Message options")
+	d, err = DecodeConfig{}.DecodeRelayMessage(data)
+	assert.NoError(t, err)
+	assert.Len(t, d.Options, 2)
+}
+
+func TestDecodeError(t *testing.T) {
+	data := []byte{
+		byte(TypeSolicit), 0x01, 0x02, 0x03,
+		0x00, 0x0e, 0x00, 0x00, // rapid commit
+		0x00, 0x08, 0x00, 0x03, 0x00, 0x00, 0x00, // elapsed time with a bad length
+	}
+	d := new(DhcpMessage)
+	err := d.UnmarshalBinary(data)
+	assert.True(t, errors.Is(err, ErrInvalidData))
+	var decodeErr *DecodeError
+	if assert.True(t, errors.As(err, &decodeErr)) {
+		assert.Equal(t, OptionCodeElapsedTime, decodeErr.OptionCode)
+		assert.Equal(t, 8, decodeErr.Offset)
+	}
+
+	err = d.UnmarshalBinary(data[:13])
+	assert.True(t, errors.Is(err, ErrUnexpectedEOF))
+	if assert.True(t, errors.As(err, &decodeErr)) {
+		assert.Equal(t, OptionCodeElapsedTime, decodeErr.OptionCode)
+		assert.Equal(t, 8, decodeErr.Offset)
+	}
+
+	relay := new(DhcpRelayMessage)
+	err = relay.UnmarshalBinary(append(make([]byte, 34), 0x00, 0x09, 0x00))
+	assert.True(t, errors.Is(err, ErrUnexpectedEOF))
+	if assert.True(t, errors.As(err, &decodeErr)) {
+		assert.Equal(t, 34, decodeErr.Offset)
+	}
+}
+
+func TestDecodeConfig_DecodeMessage(t *testing.T) {
+	//Solicit carrying only a Client Identifier
+	known := []byte{byte(TypeSolicit), 0x01, 0x02, 0x03, 0x00, 0x01, 0x00, 0x0a, 0x00, 0x03, 0x00, 0x01, 0x00, 0x00, 0x5e, 0x00, 0x53, 0x01}
+	undefined := []byte{200, 0x01, 0x02, 0x03}
+
+	d, err := DecodeConfig{}.DecodeMessage(known)
+	assert.NoError(t, err)
+	assert.Equal(t, TypeSolicit, d.MsgType)
+	d, err = DecodeConfig{}.DecodeMessage(undefined)
+	assert.NoError(t, err, "lenient mode accepts undefined types")
+	assert.Equal(t, DhcpMessageType(200), d.MsgType)
+
+	_, err = DecodeConfig{Strict: true}.DecodeMessage(known)
+	assert.NoError(t, err)
+	_, err = DecodeConfig{Strict: true}.DecodeMessage(undefined)
+	assert.Equal(t, ErrInvalidType, err)
+}
+
+func TestKnownMessageTypes(t *testing.T) {
+	types := KnownMessageTypes()
+	assert.Contains(t, types, TypeSolicit)
+	assert.Contains(t, types, TypeRelayReply)
+	assert.NotContains(t, types, DhcpMessageType(0))
+	types[0] = 0
+	assert.Equal(t, TypeSolicit, KnownMessageTypes()[0], "returns a copy")
+}
+
+func TestDecodeConfig_padding(t *testing.T) {
+	d, err := MinimalMessage(TypeSolicit)
+	assert.NoError(t, err)
+	data, err := d.MarshalBinary()
+	assert.NoError(t, err)
+
+	for _, n := range []int{1, 3, 4, 7} {
+		padded := append(append([]byte{}, data...), make([]byte, n)...)
+		decoded, err := DecodeConfig{}.DecodeMessage(padded)
+		assert.NoError(t, err, "%d octets of padding", n)
+		assert.Equal(t, d, decoded)
+
+		_, err = DecodeConfig{Strict: true}.DecodeMessage(padded)
+		assert.True(t, errors.Is(err, ErrTrailingData), "%d octets of padding", n)
+		var decodeErr *DecodeError
+		if assert.True(t, errors.As(err, &decodeErr)) {
+			assert.Equal(t, len(data), decodeErr.Offset)
+		}
+	}
+
+	//trailing data that is not all zeros is still an error
+	_, err = DecodeConfig{}.DecodeMessage(append(append([]byte{}, data...), 0x00, 0x01))
+	assert.True(t, errors.Is(err, ErrUnexpectedEOF))
+
+	//zeros ending the last option belong to it, not to the padding
+	last := &UnknownOption{OptionCode: 1000, OptionData: []byte{0x01, 0x00, 0x00}}
+	withZeros := append(mustMarshal(t, d), mustMarshal(t, last)...)
+	decoded, err := DecodeConfig{}.DecodeMessage(append(withZeros, 0x00, 0x00))
+	if assert.NoError(t, err) {
+		assert.Equal(t, last, decoded.Options[len(decoded.Options)-1])
+	}
+
+	relay := relayMessage(0, d)
+	data, err = relay.MarshalBinary()
+	assert.NoError(t, err)
+	padded := append(data, 0x00, 0x00)
+	_, err = DecodeConfig{}.DecodeRelayMessage(padded)
+	assert.NoError(t, err)
+	_, err = DecodeConfig{Strict: true}.DecodeRelayMessage(padded)
+	assert.True(t, errors.Is(err, ErrTrailingData))
+}
+
+func TestDecodeConfig_Logger(t *testing.T) {
+	d, err := MinimalMessage(TypeSolicit)
+	assert.NoError(t, err)
+	relay := relayMessage(0, d)
+	relay.Options = append(relay.Options, &InterfaceIdOption{InterfaceId: make([]byte, 200)})
+	data, err := relay.MarshalBinary()
+	assert.NoError(t, err)
+	data = append(data, 0x00, 0x00)
+
+	var warnings []string
+	c := DecodeConfig{Logger: func(format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}}
+	_, err = c.DecodeRelayMessage(data)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"Option 18: Interface-Id option is 200 octets long",
+		fmt.Sprintf("Ignored 2 bytes of padding at offset %d", len(data)-2),
+	}, warnings)
+
+	warnings = nil
+	c.Strict = true
+	_, err = c.DecodeRelayMessage(data)
+	assert.Error(t, err)
+	assert.Empty(t, warnings, "strict mode reports anomalies as errors")
+}
+
+func TestDecodeConfig_MaxOptions(t *testing.T) {
+	d, err := MinimalMessage(TypeRequest)
+	assert.NoError(t, err)
+	data, err := d.MarshalBinary()
+	assert.NoError(t, err)
+
+	_, err = DecodeConfig{MaxOptions: 3}.DecodeMessage(data)
+	assert.NoError(t, err)
+	_, err = DecodeConfig{Strict: true, MaxOptions: 2}.DecodeMessage(data)
+	assert.True(t, errors.Is(err, ErrTooManyOptions))
+	var decodeErr *DecodeError
+	if assert.True(t, errors.As(err, &decodeErr)) {
+		assert.Equal(t, OptionCodeElapsedTime, decodeErr.OptionCode)
+		assert.Equal(t, len(data)-6, decodeErr.Offset)
+	}
+
+	//the inner message's options are counted as a list of their own
+	data, err = relayMessage(0, d).MarshalBinary()
+	assert.NoError(t, err)
+	_, err = DecodeConfig{MaxOptions: 3}.DecodeRelayMessage(data)
+	assert.NoError(t, err)
+	_, err = DecodeConfig{MaxOptions: 2}.DecodeRelayMessage(data)
+	assert.True(t, errors.Is(err, ErrTooManyOptions))
+	if assert.True(t, errors.As(err, &decodeErr)) {
+		assert.Equal(t, OptionCodeElapsedTime, decodeErr.OptionCode)
+		assert.Equal(t, len(data)-6, decodeErr.Offset)
+	}
+
+	//trailing padding is not counted as options
+	data, err = d.MarshalBinary()
+	assert.NoError(t, err)
+	_, err = DecodeConfig{MaxOptions: 3}.DecodeMessage(append(data, make([]byte, 8)...))
+	assert.NoError(t, err)
+}
+
+func TestDecodeConfig_MaxOptions_default(t *testing.T) {
+	d, err := MinimalMessage(TypeRequest)
+	assert.NoError(t, err)
+	for len(d.Options) <= DefaultMaxOptions {
+		d.Options = append(d.Options, &UnknownOption{OptionCode: 1000})
+	}
+	data, err := d.MarshalBinary()
+	assert.NoError(t, err)
+
+	_, err = DecodeConfig{}.DecodeMessage(data)
+	assert.True(t, errors.Is(err, ErrTooManyOptions), "DefaultMaxOptions applies in lenient mode")
+	assert.True(t, errors.Is(err, ErrInvalidData))
+	_, err = DecodeConfig{Strict: true}.DecodeMessage(data)
+	assert.True(t, errors.Is(err, ErrTooManyOptions), "and in strict mode")
+	_, err = DecodeConfig{MaxOptions: -1}.DecodeMessage(data)
+	assert.NoError(t, err, "a negative limit removes it")
+
+	//lists nested within options keep the default
+	d.Options = []Option{&IaNaOption{IaNaOptions: d.Options[:DefaultMaxOptions+1]}}
+	data, err = d.MarshalBinary()
+	assert.NoError(t, err)
+	_, err = DecodeConfig{MaxOptions: -1}.DecodeMessage(data)
+	assert.True(t, errors.Is(err, ErrTooManyOptions))
+	d.Options[0].(*IaNaOption).IaNaOptions = d.Options[0].(*IaNaOption).IaNaOptions[:DefaultMaxOptions]
+	data, err = d.MarshalBinary()
+	assert.NoError(t, err)
+	_, err = DecodeConfig{}.DecodeMessage(data)
+	assert.NoError(t, err)
+}
+
+func TestDecodeConfig_MaxOptions_stuffed(t *testing.T) {
+	//as many empty Rapid Commit options as fit in a message
+	data := []byte{byte(TypeSolicit), 0x01, 0x02, 0x03}
+	for len(data)+4 <= 65535 {
+		data = append(data, 0x00, 0x0e, 0x00, 0x00)
+	}
+	var err error
+	allocs := testing.AllocsPerRun(1, func() {
+		_, err = DecodeConfig{}.DecodeMessage(data)
+	})
+	assert.True(t, errors.Is(err, ErrTooManyOptions))
+	assert.Less(t, allocs, float64(10), "rejected before decoding")
+
+	//the same, nested within an IA_NA
+	ia := []byte{0x00, 0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+	for len(ia)+4 <= 65535-4 {
+		ia = append(ia, 0x00, 0x0e, 0x00, 0x00)
+	}
+	binary.BigEndian.PutUint16(ia[2:], uint16(len(ia)-4))
+	data = append([]byte{byte(TypeSolicit), 0x01, 0x02, 0x03}, ia...)
+	allocs = testing.AllocsPerRun(1, func() {
+		_, err = DecodeConfig{MaxOptions: DefaultMaxOptions}.DecodeMessage(data)
+	})
+	assert.True(t, errors.Is(err, ErrTooManyOptions))
+	var decodeErr *DecodeError
+	if assert.True(t, errors.As(err, &decodeErr)) {
+		assert.Equal(t, OptionCodeRapidCommit, decodeErr.OptionCode)
+		assert.Equal(t, 4+16+4*DefaultMaxOptions, decodeErr.Offset)
+	}
+	assert.Less(t, allocs, float64(10), "rejected before decoding")
+
+	_, err = DecodeConfig{MaxOptions: -1}.DecodeMessage(data)
+	assert.True(t, errors.Is(err, ErrTooManyOptions), "the IA_NA still applies the default")
+}
+
+func TestDecodeConfig_MaxMessageSize(t *testing.T) {
+	d, err := MinimalMessage(TypeRequest)
+	assert.NoError(t, err)
+	data, err := d.MarshalBinary()
+	assert.NoError(t, err)
+
+	_, err = DecodeConfig{MaxMessageSize: len(data) + 1}.DecodeMessage(data)
+	assert.NoError(t, err, "below the limit")
+	_, err = DecodeConfig{MaxMessageSize: len(data)}.DecodeMessage(data)
+	assert.NoError(t, err, "at the limit")
+	_, err = DecodeConfig{MaxMessageSize: len(data) - 1}.DecodeMessage(data)
+	assert.True(t, errors.Is(err, ErrMessageTooLarge), "above the limit")
+
+	relay, err := relayMessage(0, d).MarshalBinary()
+	assert.NoError(t, err)
+	_, err = DecodeConfig{MaxMessageSize: len(relay) - 1}.DecodeRelayMessage(relay)
+	assert.True(t, errors.Is(err, ErrMessageTooLarge))
+
+	//the default admits anything that fits in a datagram
+	huge := append(append([]byte{}, data...), 0x03, 0xe8, 0xff, 0xff)
+	huge = append(huge, make([]byte, 65535)...)
+	_, err = DecodeConfig{}.DecodeMessage(huge)
+	assert.True(t, errors.Is(err, ErrMessageTooLarge))
+	_, err = DecodeConfig{}.DecodeMessage(huge[:DefaultMaxMessageSize])
+	assert.True(t, errors.Is(err, ErrUnexpectedEOF))
+}
@@ -2,16 +2,44 @@ package dhcpv6
 
 import (
 	"errors"
+	"fmt"
 	"io"
+	"net"
+	"time"
 )
 
 var ErrInvalidType = errors.New("Invalid type for message")
 var ErrInvalidIpv6Address = errors.New("Invalid IPv6 address")
+var ErrIpv6AddressNotSet = errors.New("IPv6 address is not set")
 var ErrUnexpectedEOF = io.ErrUnexpectedEOF
 var ErrWontFit = errors.New("The payload would exceed the size limit")
 var ErrInvalidData = errors.New("Unexpected or invalid value was encountered")
 var ErrDuidTooLong = errors.New("Duid exceeds maximum length of 128 octets")
 var ErrNotImplemented = errors.New("Not implemented yet")
+var ErrMissingRelayMsg = errors.New("Relay message does not contain a Relay Message option")
+var ErrTransactionIdMismatch = errors.New("Transaction id does not match the request")
+var ErrUnexpectedMessageType = errors.New("Message type is not a valid response to the request")
+var ErrClientIdMismatch = errors.New("Client id does not match the request")
+var ErrHopCountExceeded = errors.New("Relay chain exceeds the hop count limit")
+var ErrDuplicateIaid = errors.New("Multiple IAs of the same type share an IAID")
+var ErrInvalidOptionRequest = errors.New("Option Request option requests an option that is never requested")
+var ErrTrailingData = errors.New("Message has data following its last option")
+var ErrUnsupportedAuth = errors.New("Unsupported authentication protocol, algorithm and RDM combination")
+var ErrNoHardwareAddr = errors.New("Interface has no usable hardware address")
+var ErrNoLinkLocal = errors.New("Interface has no IPv6 link-local address")
+var ErrMissingOption = errors.New("Message is missing a required option")
+var ErrForbiddenOption = errors.New("Message carries an option not permitted for its type")
+var ErrDuplicateOption = errors.New("Option may appear only once in a message")
+var ErrTooManyOptions = fmt.Errorf("%w: more options than permitted in one list", ErrInvalidData)
+var ErrMessageTooLarge = errors.New("Message exceeds the maximum size")
+var ErrSessionState = errors.New("Client session can not take this step in its current state")
+var ErrStatusCode = errors.New("Server responded with an unsuccessful status code")
+var ErrNoResponse = errors.New("No response was received from a server")
+var ErrPoolExhausted = errors.New("No free transaction id could be found")
+
+// Now is used by every helper that needs the current time. It may be replaced
+// (e.g. in tests) to provide a deterministic clock.
+var Now = time.Now
 
 const (
 	//addresses
@@ -22,13 +50,63 @@ const (
 	PortClient = 546
 	PortServer = 547
 
+	Infinity = 0xffffffff
+
+	// InfiniteLifetime marks a lifetime (or T1/T2 timer) that never expires.
+	InfiniteLifetime = Infinity
+)
+
+// StatusCode is the code carried by a Status Code option.
+type StatusCode uint16
+
+const (
 	//Status Codes
-	Success = iota
+	Success StatusCode = iota
 	UnspecFail
 	NoAddrsAvail
 	NoBinding
 	NotOnLink
 	UseMulticast
-
-	Infinity = 0xffffffff
 )
+
+// HopCountLimit is the maximum number of relay agents a message may pass
+// through.
+const HopCountLimit = 32
+
+// cloneBytes returns a copy of b, so that decoded values do not alias the
+// caller's buffer.
+func cloneBytes(b []byte) []byte {
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
+}
+
+// checkIpv6Address returns ErrIpv6AddressNotSet for an empty address, and
+// ErrInvalidIpv6Address if ip is not in its 16-byte form.
+func checkIpv6Address(ip net.IP) error {
+	if len(ip) == 0 {
+		return ErrIpv6AddressNotSet
+	}
+	if len(ip) != net.IPv6len {
+		return ErrInvalidIpv6Address
+	}
+	return nil
+}
+
+// AllRelayAgentsAndServersAddr returns the link-scoped multicast address used
+// by clients to reach all relay agents and servers on the link.
+func AllRelayAgentsAndServersAddr() *net.UDPAddr {
+	return &net.UDPAddr{
+		IP:   net.ParseIP(AddressAllDhcpRelayAgentsAndServers),
+		Port: PortServer,
+	}
+}
+
+// AllServersAddr returns the site-scoped multicast address used by relay
+// agents to reach all servers.
+func AllServersAddr() *net.UDPAddr {
+	return &net.UDPAddr{
+		IP:   net.ParseIP(AddressAllDhcpServers),
+		Port: PortServer,
+	}
+}
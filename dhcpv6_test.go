@@ -0,0 +1,33 @@
+package dhcpv6
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+func TestAllRelayAgentsAndServersAddr(t *testing.T) {
+	addr := AllRelayAgentsAndServersAddr()
+	assert.True(t, net.ParseIP("ff02::1:2").Equal(addr.IP))
+	assert.Equal(t, PortServer, addr.Port)
+}
+
+func TestAllServersAddr(t *testing.T) {
+	addr := AllServersAddr()
+	assert.True(t, net.ParseIP("ff05::1:3").Equal(addr.IP))
+	assert.Equal(t, PortServer, addr.Port)
+}
+
+func TestStatusCode(t *testing.T) {
+	//values from RFC 3315 section 24.4
+	assert.Equal(t, StatusCode(0), Success)
+	assert.Equal(t, StatusCode(1), UnspecFail)
+	assert.Equal(t, StatusCode(2), NoAddrsAvail)
+	assert.Equal(t, StatusCode(3), NoBinding)
+	assert.Equal(t, StatusCode(4), NotOnLink)
+	assert.Equal(t, StatusCode(5), UseMulticast)
+
+	data, err := (&StatusCodeOption{StatusCode: NoAddrsAvail}).MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x00, 0x0d, 0x00, 0x02, 0x00, 0x02}, data)
+}
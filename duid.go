@@ -1,8 +1,12 @@
 package dhcpv6
 
 import (
+	"bytes"
 	"encoding"
 	"encoding/binary"
+	"math"
+	"net"
+	"time"
 )
 
 // The motivation for having more than one type of DUID is that the DUID
@@ -25,6 +29,33 @@ const (
 	DuidTypeLl DuidType = 3
 )
 
+// Hardware types (https://www.iana.org/assignments/arp-parameters) for the
+// link-layer addresses used in DUID-LLT and DUID-LL.
+const (
+	HardwareTypeEthernet   uint16 = 1
+	HardwareTypeEui64      uint16 = 27
+	HardwareTypeInfiniband uint16 = 32
+)
+
+// interfaceHardware returns the hardware type and a copy of the address of
+// iface, guessing the type from the length of the address.
+func interfaceHardware(iface net.Interface) (uint16, []byte, error) {
+	var hardwareType uint16
+	switch len(iface.HardwareAddr) {
+	case 0:
+		return 0, nil, ErrNoHardwareAddr
+	case 6:
+		hardwareType = HardwareTypeEthernet
+	case 8:
+		hardwareType = HardwareTypeEui64
+	case 20:
+		hardwareType = HardwareTypeInfiniband
+	default:
+		return 0, nil, ErrNoHardwareAddr
+	}
+	return hardwareType, cloneBytes(iface.HardwareAddr), nil
+}
+
 // DHCP Unique Identifier (DUID)
 // Each DHCP client and server has a DUID.  DHCP servers use DUIDs to
 // identify clients for the selection of configuration parameters and in
@@ -44,6 +75,9 @@ type Duid interface {
 // UnmarshalBinaryDuid will take the raw wire-format data and construct
 // the correct structure underneath, returning the Duid interface.
 func UnmarshalBinaryDuid(data []byte) (duid Duid, err error) {
+	if len(data) < 2 {
+		return nil, ErrUnexpectedEOF
+	}
 	dtype := binary.BigEndian.Uint16(data)
 	switch DuidType(dtype) {
 	case DuidTypeLlt:
@@ -61,6 +95,53 @@ func UnmarshalBinaryDuid(data []byte) (duid Duid, err error) {
 	return
 }
 
+// DuidEqual reports whether a and b are the same DUID, by comparing their
+// wire-format. A DUID that cannot be marshaled is never equal to anything.
+func DuidEqual(a, b Duid) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	aData, err := a.MarshalBinary()
+	if err != nil {
+		return false
+	}
+	bData, err := b.MarshalBinary()
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(aData, bData)
+}
+
+// DuidCompare orders a and b by their wire-format, returning -1, 0 or +1 as
+// with bytes.Compare, so that DUIDs may be used as sorted keys. A nil DUID, or
+// one that cannot be marshaled, sorts before all others.
+func DuidCompare(a, b Duid) int {
+	return bytes.Compare(duidKey(a), duidKey(b))
+}
+
+func duidKey(d Duid) []byte {
+	if d == nil {
+		return nil
+	}
+	data, err := d.MarshalBinary()
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// duidHardwareAddr returns a copy of the link-layer address held by a
+// DUID-LLT or DUID-LL.
+func duidHardwareAddr(d Duid) (net.HardwareAddr, bool) {
+	switch d := d.(type) {
+	case *LltDuid:
+		return net.HardwareAddr(cloneBytes(d.LlAddress)), true
+	case *LlDuid:
+		return net.HardwareAddr(cloneBytes(d.LlAddress)), true
+	}
+	return nil, false
+}
+
 // DUID Based on Link-layer Address Plus Time [DUID-LLT]
 //
 // https://tools.ietf.org/html/rfc3315#section-9.2
@@ -70,6 +151,59 @@ type LltDuid struct {
 	LlAddress    []byte
 }
 
+// DuidEpoch is the reference point for the DUID-LLT time field: midnight
+// (UTC), January 1, 2000.
+var DuidEpoch = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// GenerateLltDuid will create a DUID-LLT for the given link-layer address,
+// stamped with the current time (see Now).
+func GenerateLltDuid(hardwareType uint16, llAddress []byte) *LltDuid {
+	return &LltDuid{
+		HardwareType: hardwareType,
+		Time:         duidTime(Now()),
+		LlAddress:    llAddress,
+	}
+}
+
+// NewLltDuid will create a DUID-LLT from the hardware address of iface,
+// stamped with t. Times before DuidEpoch are stored as 0.
+func NewLltDuid(iface net.Interface, t time.Time) (*LltDuid, error) {
+	hardwareType, addr, err := interfaceHardware(iface)
+	if err != nil {
+		return nil, err
+	}
+	return &LltDuid{
+		HardwareType: hardwareType,
+		Time:         duidTime(t),
+		LlAddress:    addr,
+	}, nil
+}
+
+// duidTime converts t to seconds since DuidEpoch, clamped to the range of
+// the DUID-LLT time field.
+func duidTime(t time.Time) uint32 {
+	if t.Before(DuidEpoch) {
+		return 0
+	}
+	seconds := t.Sub(DuidEpoch) / time.Second
+	if seconds > math.MaxUint32 {
+		return math.MaxUint32
+	}
+	return uint32(seconds)
+}
+
+// SetTime sets the time field of the DUID to t, as seconds since DuidEpoch.
+// Times before DuidEpoch are stored as 0, and times after the field would
+// overflow (in 2136) as its maximum value.
+func (d *LltDuid) SetTime(t time.Time) {
+	d.Time = duidTime(t)
+}
+
+// GetTime returns the time field of the DUID as a UTC time.
+func (d *LltDuid) GetTime() time.Time {
+	return DuidEpoch.Add(time.Duration(d.Time) * time.Second)
+}
+
 func (d *LltDuid) Type() DuidType {
 	return DuidTypeLlt
 }
@@ -96,7 +230,7 @@ func (d *LltDuid) UnmarshalBinary(data []byte) error {
 	}
 	d.HardwareType = binary.BigEndian.Uint16(data[2:])
 	d.Time = binary.BigEndian.Uint32(data[4:])
-	d.LlAddress = data[8:]
+	d.LlAddress = cloneBytes(data[8:])
 	return nil
 }
 
@@ -132,7 +266,7 @@ func (d *EnDuid) UnmarshalBinary(data []byte) error {
 		return ErrInvalidType
 	}
 	d.EnterpriseNumber = binary.BigEndian.Uint32(data[2:])
-	d.Identifier = data[6:]
+	d.Identifier = cloneBytes(data[6:])
 	data = data[len(data):]
 	return nil
 }
@@ -145,6 +279,15 @@ type LlDuid struct {
 	LlAddress    []byte
 }
 
+// NewLlDuid will create a DUID-LL from the hardware address of iface.
+func NewLlDuid(iface net.Interface) (*LlDuid, error) {
+	hardwareType, addr, err := interfaceHardware(iface)
+	if err != nil {
+		return nil, err
+	}
+	return &LlDuid{HardwareType: hardwareType, LlAddress: addr}, nil
+}
+
 func (d *LlDuid) Type() DuidType {
 	return DuidTypeLl
 }
@@ -169,6 +312,6 @@ func (d *LlDuid) UnmarshalBinary(data []byte) error {
 		return ErrInvalidType
 	}
 	d.HardwareType = binary.BigEndian.Uint16(data[2:])
-	d.LlAddress = data[4:]
+	d.LlAddress = cloneBytes(data[4:])
 	return nil
 }
@@ -2,8 +2,12 @@ package dhcpv6
 
 import (
 	"github.com/stretchr/testify/assert"
+	"math"
+	"net"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestLltDuid_Type(t *testing.T) {
@@ -78,3 +82,96 @@ func TestLlDuid_UnmarshalBinary(t *testing.T) {
 	assert.Equal(t, 0x42, d.HardwareType)
 	assert.Equal(t, []byte("hello world"), d.LlAddress)
 }
+
+func TestGenerateLltDuid(t *testing.T) {
+	defer func(now func() time.Time) { Now = now }(Now)
+	Now = func() time.Time { return time.Date(2000, time.January, 1, 1, 0, 0, 0, time.UTC) }
+
+	d := GenerateLltDuid(1, []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55})
+	assert.Equal(t, uint16(1), d.HardwareType)
+	assert.Equal(t, uint32(3600), d.Time)
+	assert.Equal(t, []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}, d.LlAddress)
+}
+
+func TestNewLlDuid(t *testing.T) {
+	iface := net.Interface{HardwareAddr: net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}}
+	d, err := NewLlDuid(iface)
+	assert.NoError(t, err)
+	assert.Equal(t, &LlDuid{HardwareType: HardwareTypeEthernet, LlAddress: []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}}, d)
+
+	iface.HardwareAddr[0] = 0xff
+	assert.Equal(t, byte(0x00), d.LlAddress[0], "address must be copied")
+
+	_, err = NewLlDuid(net.Interface{Name: "lo"})
+	assert.Equal(t, ErrNoHardwareAddr, err)
+}
+
+func TestNewLltDuid(t *testing.T) {
+	iface := net.Interface{HardwareAddr: net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77}}
+	d, err := NewLltDuid(iface, time.Date(2000, time.January, 2, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, HardwareTypeEui64, d.HardwareType)
+	assert.Equal(t, uint32(86400), d.Time)
+
+	d, err = NewLltDuid(iface, time.Date(1999, time.January, 1, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(0), d.Time, "before the epoch")
+
+	_, err = NewLltDuid(net.Interface{}, time.Now())
+	assert.Equal(t, ErrNoHardwareAddr, err)
+}
+
+func TestLltDuid_SetTime(t *testing.T) {
+	d := new(LltDuid)
+	//2036 is where 32-bit seconds since 1900 (NTP) wrap, but not this epoch
+	when := time.Date(2036, time.February, 7, 6, 28, 16, 0, time.UTC)
+	d.SetTime(when)
+	assert.Equal(t, uint32(1139293696), d.Time)
+	assert.Equal(t, when, d.GetTime())
+
+	d.SetTime(time.Date(1999, time.December, 31, 23, 59, 59, 0, time.UTC))
+	assert.Equal(t, uint32(0), d.Time, "before the epoch")
+	assert.Equal(t, DuidEpoch, d.GetTime())
+
+	d.SetTime(time.Date(2200, time.January, 1, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, uint32(math.MaxUint32), d.Time, "past the end of the field")
+	assert.Equal(t, time.Date(2136, time.February, 7, 6, 28, 15, 0, time.UTC), d.GetTime())
+}
+
+func TestDuidEqual(t *testing.T) {
+	a := &LlDuid{HardwareType: 1, LlAddress: []byte{0x01, 0x02}}
+	assert.True(t, DuidEqual(a, &LlDuid{HardwareType: 1, LlAddress: []byte{0x01, 0x02}}))
+	assert.False(t, DuidEqual(a, &LlDuid{HardwareType: 1, LlAddress: []byte{0x01, 0x03}}))
+	assert.False(t, DuidEqual(a, &EnDuid{EnterpriseNumber: 1, Identifier: []byte{0x01, 0x02}}))
+	assert.False(t, DuidEqual(a, nil))
+}
+
+func TestDuidCompare(t *testing.T) {
+	llt := &LltDuid{HardwareType: 1, Time: 100, LlAddress: []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}}
+	en := &EnDuid{EnterpriseNumber: 9, Identifier: []byte{0x01}}
+	ll := &LlDuid{HardwareType: 1, LlAddress: []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}}
+	ll2 := &LlDuid{HardwareType: 1, LlAddress: []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x56}}
+	llShort := &LlDuid{HardwareType: 1, LlAddress: []byte{0x00, 0x11, 0x22, 0x33, 0x44}}
+
+	assert.Equal(t, -1, DuidCompare(llt, en), "across types, by type code")
+	assert.Equal(t, -1, DuidCompare(en, ll))
+	assert.Equal(t, 1, DuidCompare(ll, llt))
+	assert.Equal(t, -1, DuidCompare(ll, ll2), "within a type, by identifier")
+	assert.Equal(t, -1, DuidCompare(llShort, ll), "prefix sorts first")
+	assert.Equal(t, 0, DuidCompare(ll, &LlDuid{HardwareType: 1, LlAddress: []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}}))
+	assert.Equal(t, -1, DuidCompare(nil, en))
+	assert.Equal(t, 0, DuidCompare(nil, nil))
+
+	duids := []Duid{ll2, llt, ll, en, llShort}
+	sort.Slice(duids, func(i, j int) bool { return DuidCompare(duids[i], duids[j]) < 0 })
+	assert.Equal(t, []Duid{llt, en, llShort, ll, ll2}, duids)
+}
+
+func TestUnmarshalBinaryDuid(t *testing.T) {
+	_, err := UnmarshalBinaryDuid(nil)
+	assert.Equal(t, ErrUnexpectedEOF, err)
+	_, err = UnmarshalBinaryDuid([]byte{0x00})
+	assert.Equal(t, ErrUnexpectedEOF, err)
+	_, err = UnmarshalBinaryDuid([]byte{0x00, 0x09})
+	assert.Equal(t, ErrInvalidType, err)
+}
@@ -0,0 +1,286 @@
+package dhcpv6
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// messageTypeNames holds the names used by the RFCs for each message type.
+var messageTypeNames = map[DhcpMessageType]string{
+	TypeSolicit:            "SOLICIT",
+	TypeAdvertise:          "ADVERTISE",
+	TypeRequest:            "REQUEST",
+	TypeConfirm:            "CONFIRM",
+	TypeRenew:              "RENEW",
+	TypeRebind:             "REBIND",
+	TypeReply:              "REPLY",
+	TypeRelease:            "RELEASE",
+	TypeDecline:            "DECLINE",
+	TypeReconfigure:        "RECONFIGURE",
+	TypeInformationRequest: "INFORMATION-REQUEST",
+	TypeRelayForward:       "RELAY-FORW",
+	TypeRelayReply:         "RELAY-REPL",
+	TypeLeasequery:         "LEASEQUERY",
+	TypeLeasequeryReply:    "LEASEQUERY-REPLY",
+	TypeLeasequeryDone:     "LEASEQUERY-DONE",
+	TypeLeasequeryData:     "LEASEQUERY-DATA",
+	TypeActiveLeasequery:   "ACTIVELEASEQUERY",
+	TypeStartTls:           "STARTTLS",
+}
+
+var statusCodeNames = map[StatusCode]string{
+	Success:      "Success",
+	UnspecFail:   "UnspecFail",
+	NoAddrsAvail: "NoAddrsAvail",
+	NoBinding:    "NoBinding",
+	NotOnLink:    "NotOnLink",
+	UseMulticast: "UseMulticast",
+}
+
+var duidTypeNames = map[DuidType]string{
+	DuidTypeLlt: "DUID-LLT",
+	DuidTypeEn:  "DUID-EN",
+	DuidTypeLl:  "DUID-LL",
+}
+
+func unknownName(n int) string {
+	return "Unknown(" + strconv.Itoa(n) + ")"
+}
+
+// String returns the name of the message type (e.g. "SOLICIT"), or
+// Unknown(N) for a type not defined by this package.
+func (t DhcpMessageType) String() string {
+	if name, ok := messageTypeNames[t]; ok {
+		return name
+	}
+	return unknownName(int(t))
+}
+
+// String returns the name of the option code (e.g. "IA_NA"), or Unknown(N)
+// for a code without a registered type.
+func (c OptionCode) String() string {
+	if t, ok := optionTypes[c]; ok {
+		return t.name
+	}
+	return unknownName(int(c))
+}
+
+// String returns the name of the DUID type (e.g. "DUID-LLT").
+func (t DuidType) String() string {
+	if name, ok := duidTypeNames[t]; ok {
+		return name
+	}
+	return unknownName(int(t))
+}
+
+// String returns the name of the status code (e.g. "NoBinding").
+func (c StatusCode) String() string {
+	if name, ok := statusCodeNames[c]; ok {
+		return name
+	}
+	return unknownName(int(c))
+}
+
+// formatOptions renders each option in brackets, preceded by a space.
+func formatOptions(options []Option) string {
+	var b strings.Builder
+	for _, o := range options {
+		fmt.Fprintf(&b, " [%v]", o)
+	}
+	return b.String()
+}
+
+func formatDuid(d Duid) string {
+	if d == nil {
+		return "<nil>"
+	}
+	data, err := d.MarshalBinary()
+	if err != nil {
+		return "<invalid>"
+	}
+	return hex.EncodeToString(data)
+}
+
+func formatIPs(ips []net.IP) string {
+	s := make([]string, len(ips))
+	for i, ip := range ips {
+		s[i] = ip.String()
+	}
+	return strings.Join(s, ",")
+}
+
+// printableOrHex renders b as text when it is entirely printable ASCII, and
+// as hex otherwise.
+func printableOrHex(b []byte) string {
+	for _, c := range b {
+		if c < 0x20 || c > 0x7e {
+			return hex.EncodeToString(b)
+		}
+	}
+	return string(b)
+}
+
+func formatData(data [][]byte) string {
+	s := make([]string, len(data))
+	for i, d := range data {
+		s[i] = printableOrHex(d)
+	}
+	return strings.Join(s, ",")
+}
+
+func (d *DhcpMessage) String() string {
+	return fmt.Sprintf("%v xid=%s%s", d.MsgType, d.TransactionIdString(), formatOptions(d.Options))
+}
+func (d *DhcpRelayMessage) String() string {
+	return fmt.Sprintf("%v hops=%d link=%v peer=%v%s", d.MsgType, d.HopCount, d.LinkAddress, d.PeerAddress, formatOptions(d.Options))
+}
+
+func (o *UnknownOption) String() string {
+	return fmt.Sprintf("%v %x", o.OptionCode, o.OptionData)
+}
+func (o *ClientIdOption) String() string {
+	return "CLIENTID " + formatDuid(o.Duid)
+}
+func (o *ServerIdOption) String() string {
+	return "SERVERID " + formatDuid(o.Duid)
+}
+func (o *IaNaOption) String() string {
+	return fmt.Sprintf("IA_NA IAID=%x T1=%d T2=%d%s", o.IAID, o.T1, o.T2, formatOptions(o.IaNaOptions))
+}
+func (o *IaTaOption) String() string {
+	return fmt.Sprintf("IA_TA IAID=%x%s", o.IAID, formatOptions(o.IaTaOptions))
+}
+func (o *IaAddrOption) String() string {
+	return fmt.Sprintf("IAADDR %v preferred=%d valid=%d%s", o.Ipv6Address, o.PreferredLifetime, o.ValidLifetime, formatOptions(o.IAddrOptions))
+}
+func (o *IaPdOption) String() string {
+	return fmt.Sprintf("IA_PD IAID=%x T1=%d T2=%d%s", o.IAID, o.T1, o.T2, formatOptions(o.IaPdOptions))
+}
+func (o *IaPrefixOption) String() string {
+	return fmt.Sprintf("IAPREFIX %v/%d preferred=%d valid=%d%s", o.Ipv6Prefix, o.PrefixLength, o.PreferredLifetime, o.ValidLifetime, formatOptions(o.IaPrefixOptions))
+}
+func (o *OroOption) String() string {
+	s := make([]string, len(o.RequestedOptionCodes))
+	for i, code := range o.RequestedOptionCodes {
+		s[i] = OptionCode(code).String()
+	}
+	return "ORO " + strings.Join(s, ",")
+}
+func (o *PreferenceOption) String() string {
+	return fmt.Sprintf("PREFERENCE %d", o.PreferenceValue)
+}
+func (o *ElapsedTimeOption) String() string {
+	return fmt.Sprintf("ELAPSED_TIME %d", o.ElapsedTime)
+}
+func (o *RelayMsgOption) String() string {
+	if o.RawInner != nil {
+		return fmt.Sprintf("RELAY_MSG raw=%x", o.RawInner)
+	}
+	return fmt.Sprintf("RELAY_MSG [%v]", o.DhcpRelayMessage)
+}
+func (o *AuthOption) String() string {
+	return fmt.Sprintf("AUTH protocol=%d algorithm=%d rdm=%d replay=%x info=%x", o.Protocol, o.Algorithm, o.RDM, o.ReplayDetection, o.AuthenticationInformation)
+}
+func (o *UnicastOption) String() string {
+	return fmt.Sprintf("UNICAST %v", o.ServerAddress)
+}
+func (o *StatusCodeOption) String() string {
+	return fmt.Sprintf("STATUS_CODE %v %q", o.StatusCode, o.StatusMessage)
+}
+func (o *RapidCommitOption) String() string {
+	return "RAPID_COMMIT"
+}
+func (o *UserClassOption) String() string {
+	return "USER_CLASS " + formatData(o.UserClassData)
+}
+func (o *VendorClassOption) String() string {
+	return "VENDOR_CLASS " + formatData(o.VendorClassData)
+}
+func (o *VendorOptsOption) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "VENDOR_OPTS enterprise=%d", o.EnterpriseNumber)
+	for _, d := range o.OptionData {
+		fmt.Fprintf(&b, " %d=%s", d.OptionCode, printableOrHex(d.OptionData))
+	}
+	return b.String()
+}
+func (o *InterfaceIdOption) String() string {
+	return "INTERFACE_ID " + printableOrHex(o.InterfaceId)
+}
+func (o *ReconfMsgOption) String() string {
+	return "RECONF_MSG " + DhcpMessageType(o.MsgType).String()
+}
+func (o *ReconfAcceptOption) String() string {
+	return "RECONF_ACCEPT"
+}
+func (o *NextHopOption) String() string {
+	return fmt.Sprintf("NEXT_HOP %v%s", o.NextHop, formatOptions(o.NextHopOptions))
+}
+func (o *RtPrefixOption) String() string {
+	return fmt.Sprintf("RTPREFIX %v/%d lifetime=%d metric=%d", o.Prefix, o.Prefixlen, o.Lifetime, o.Metric)
+}
+func (o *DnsServersOption) String() string {
+	return "DNS_SERVERS " + formatIPs(o.Servers)
+}
+func (o *DomainSearchListOption) String() string {
+	return "DOMAIN_LIST " + strings.Join(o.DomainNames, ",")
+}
+func (o *InformationRefreshTimeOption) String() string {
+	return fmt.Sprintf("INFORMATION_REFRESH_TIME %d", o.RefreshTime)
+}
+func (o *NtpServerOption) String() string {
+	var b strings.Builder
+	b.WriteString("NTP_SERVER")
+	for _, sub := range o.Suboptions {
+		switch sub.Type {
+		case NtpSuboptionSrvAddr:
+			fmt.Fprintf(&b, " srv=%v", sub.Address)
+		case NtpSuboptionMcAddr:
+			fmt.Fprintf(&b, " mc=%v", sub.Address)
+		case NtpSuboptionSrvFqdn:
+			fmt.Fprintf(&b, " fqdn=%s", sub.Fqdn)
+		default:
+			fmt.Fprintf(&b, " %s", unknownName(int(sub.Type)))
+		}
+	}
+	return b.String()
+}
+func (o *RemoteIdOption) String() string {
+	return fmt.Sprintf("REMOTE_ID enterprise=%d %s", o.EnterpriseNumber, printableOrHex(o.RemoteId))
+}
+func (o *SubscriberIdOption) String() string {
+	return "SUBSCRIBER_ID " + printableOrHex(o.SubscriberId)
+}
+func (o *FQDNOption) String() string {
+	flags := ""
+	for _, f := range []struct {
+		bit  uint8
+		name string
+	}{{FQDNFlagN, "N"}, {FQDNFlagO, "O"}, {FQDNFlagS, "S"}} {
+		if o.Flags&f.bit != 0 {
+			flags += f.name
+		}
+	}
+	return fmt.Sprintf("CLIENT_FQDN flags=%s %s", flags, o.DomainName)
+}
+func (o *MTUOption) String() string {
+	return fmt.Sprintf("MTU %d", o.MTU)
+}
+func (o *S46RuleOption) String() string {
+	return fmt.Sprintf("S46_RULE flags=%d ea-len=%d %v/%d %v/%d%s", o.Flags, o.EaLen, o.Ipv4Prefix, o.Prefix4Len, o.Ipv6Prefix, o.Prefix6Len, formatOptions(o.S46RuleOptions))
+}
+func (o *S46BrOption) String() string {
+	return fmt.Sprintf("S46_BR %v", o.BrAddress)
+}
+func (o *S46ContMapEOption) String() string {
+	return "S46_CONT_MAPE" + formatOptions(o.S46Options)
+}
+func (o *S46ContMapTOption) String() string {
+	return "S46_CONT_MAPT" + formatOptions(o.S46Options)
+}
+func (o *S46ContLwOption) String() string {
+	return "S46_CONT_LW" + formatOptions(o.S46Options)
+}
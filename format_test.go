@@ -0,0 +1,75 @@
+package dhcpv6
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+func TestDhcpMessage_String(t *testing.T) {
+	assert.Equal(t,
+		"SOLICIT xid=a0a7a2 [RAPID_COMMIT] [IA_NA IAID=afaaaca3 T1=0 T2=0] [ORO DNS_SERVERS,DOMAIN_LIST,NTP_SERVER] [CLIENTID 00020000ab11aca2a8afaea3a3af] [ELAPSED_TIME 0]",
+		exampleSolicit().String(),
+	)
+
+	d := goldenMessages(t)["reply_ia_na"]
+	d.Options = append(d.Options[2:3], &StatusCodeOption{StatusCode: NoBinding, StatusMessage: "gone"})
+	assert.Equal(t,
+		`REPLY xid=123456 [IA_NA IAID=00000001 T1=1800 T2=2880 [IAADDR 2001:db8::1 preferred=3600 valid=7200]] [STATUS_CODE NoBinding "gone"]`,
+		d.String(),
+	)
+}
+
+func TestDhcpRelayMessage_String(t *testing.T) {
+	relay := &DhcpRelayMessage{
+		MsgType:     TypeRelayForward,
+		LinkAddress: net.ParseIP("2001:db8::1"),
+		PeerAddress: net.ParseIP("fe80::1"),
+		Options: []Option{
+			&InterfaceIdOption{InterfaceId: []byte("eth0")},
+			&RelayMsgOption{DhcpRelayMessage: &DhcpMessage{MsgType: TypeSolicit, TransactionId: [3]byte{1, 2, 3}}},
+		},
+	}
+	assert.Equal(t, "RELAY-FORW hops=0 link=2001:db8::1 peer=fe80::1 [INTERFACE_ID eth0] [RELAY_MSG [SOLICIT xid=010203]]", relay.String())
+}
+
+func TestOption_String(t *testing.T) {
+	assert.Equal(t, "Unknown(1000) aabb", (&UnknownOption{OptionCode: 1000, OptionData: []byte{0xaa, 0xbb}}).String())
+	assert.Equal(t, "STATUS_CODE Unknown(99) \"\"", (&StatusCodeOption{StatusCode: 99}).String())
+	assert.Equal(t, "IAPREFIX 2001:db8:1::/48 preferred=3600 valid=7200", (&IaPrefixOption{
+		PreferredLifetime: 3600,
+		ValidLifetime:     7200,
+		PrefixLength:      48,
+		Ipv6Prefix:        net.ParseIP("2001:db8:1::"),
+	}).String())
+	assert.Equal(t, "CLIENT_FQDN flags=S host.example.com", (&FQDNOption{Flags: FQDNFlagS, DomainName: "host.example.com"}).String())
+
+	for code := range RegisteredOptionCodes() {
+		_, ok := NewOptionByCode(code).(fmt.Stringer)
+		assert.True(t, ok, "option %d must implement fmt.Stringer", code)
+	}
+}
+
+func TestOptionCode_String(t *testing.T) {
+	assert.Equal(t, "IA_NA", OptionCodeIaNa.String())
+	assert.Equal(t, "Unknown(1000)", OptionCode(1000).String())
+	for code, name := range RegisteredOptionCodes() {
+		assert.Equal(t, name, code.String())
+	}
+}
+
+func TestDhcpMessageType_String(t *testing.T) {
+	assert.Equal(t, "SOLICIT", TypeSolicit.String())
+	assert.Equal(t, "Unknown(200)", DhcpMessageType(200).String())
+	for _, typ := range KnownMessageTypes() {
+		assert.NotContains(t, typ.String(), "Unknown", "message type %d", typ)
+	}
+}
+
+func TestDuidType_String(t *testing.T) {
+	assert.Equal(t, "DUID-LLT", DuidTypeLlt.String())
+	assert.Equal(t, "DUID-EN", DuidTypeEn.String())
+	assert.Equal(t, "DUID-LL", DuidTypeLl.String())
+	assert.Equal(t, "Unknown(4)", DuidType(4).String())
+}
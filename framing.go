@@ -0,0 +1,55 @@
+package dhcpv6
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// ReadMessage will read a single message from r, as framed for the TCP
+// transport used by bulk and active leasequery (RFC 5460, RFC 7653).
+//
+// On TCP, each message is preceded by a 2-octet length in network byte order.
+// Messages received over UDP carry no such prefix and should be decoded with
+// DhcpMessage.UnmarshalBinary instead.
+//
+// io.EOF is returned only when r ends cleanly between messages. A message cut
+// short, whether in the length prefix, the header or part way through an
+// option, results in an error matching io.ErrUnexpectedEOF with errors.Is.
+func ReadMessage(r io.Reader) (*DhcpMessage, error) {
+	var prefix [2]byte
+	_, err := io.ReadFull(r, prefix[:])
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, binary.BigEndian.Uint16(prefix[:]))
+	_, err = io.ReadFull(r, data)
+	if err == io.EOF {
+		return nil, ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	d := new(DhcpMessage)
+	err = d.UnmarshalBinary(data)
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// WriteFramedMessage will write d to w, preceded by the 2-octet length used
+// by the TCP transport. See ReadMessage.
+func WriteFramedMessage(w io.Writer, d *DhcpMessage) error {
+	msgData, err := d.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if len(msgData) > 65535 {
+		return ErrWontFit
+	}
+	data := make([]byte, 2+len(msgData))
+	binary.BigEndian.PutUint16(data, uint16(len(msgData)))
+	copy(data[2:], msgData)
+	_, err = w.Write(data)
+	return err
+}
@@ -0,0 +1,78 @@
+package dhcpv6
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+)
+
+func TestReadMessage(t *testing.T) {
+	expected := &DhcpMessage{
+		MsgType:       TypeReply,
+		TransactionId: [3]byte{0x01, 0x02, 0x03},
+		Options: []Option{
+			&StatusCodeOption{StatusCode: Success, StatusMessage: "ok"},
+		},
+	}
+	msgData, err := expected.MarshalBinary()
+	assert.NoError(t, err)
+	buf := bytes.NewBuffer([]byte{0x00, byte(len(msgData))})
+	buf.Write(msgData)
+
+	d, err := ReadMessage(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, d)
+	_, err = ReadMessage(buf)
+	assert.Equal(t, io.EOF, err)
+
+	_, err = ReadMessage(bytes.NewReader([]byte{0x00, 0x08, 0x07, 0x01, 0x02}))
+	assert.Equal(t, ErrUnexpectedEOF, err, "truncated message")
+
+	_, err = ReadMessage(bytes.NewReader([]byte{0x00}))
+	assert.Equal(t, io.ErrUnexpectedEOF, err, "truncated length prefix")
+
+	//a complete frame, holding a client id option claiming 10 bytes of data
+	_, err = ReadMessage(bytes.NewReader([]byte{0x00, 0x0a, 0x07, 0x01, 0x02, 0x03, 0x00, 0x01, 0x00, 0x0a, 0x00, 0x03}))
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF, "truncated option")
+}
+
+func TestWriteFramedMessage(t *testing.T) {
+	messages := []*DhcpMessage{
+		{
+			MsgType:       TypeActiveLeasequery,
+			TransactionId: [3]byte{0x01, 0x02, 0x03},
+			Options: []Option{
+				&ClientIdOption{Duid: &LlDuid{HardwareType: 1, LlAddress: []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}}},
+			},
+		},
+		{
+			MsgType:       TypeLeasequeryData,
+			TransactionId: [3]byte{0x01, 0x02, 0x03},
+			Options: []Option{
+				&StatusCodeOption{StatusCode: Success, StatusMessage: "ok"},
+			},
+		},
+		{
+			MsgType:       TypeLeasequeryDone,
+			TransactionId: [3]byte{0x01, 0x02, 0x03},
+			Options:       []Option{},
+		},
+		{
+			MsgType:       TypeStartTls,
+			TransactionId: [3]byte{0x04, 0x05, 0x06},
+			Options:       []Option{},
+		},
+	}
+	buf := new(bytes.Buffer)
+	for _, d := range messages {
+		assert.NoError(t, WriteFramedMessage(buf, d))
+	}
+	for _, expected := range messages {
+		d, err := ReadMessage(buf)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, d)
+	}
+	_, err := ReadMessage(buf)
+	assert.Equal(t, io.EOF, err)
+}
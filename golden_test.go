@@ -0,0 +1,191 @@
+package dhcpv6
+
+import (
+	"encoding"
+	"flag"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata/golden")
+
+// goldenMessages returns a set of named, canonical messages whose wire format
+// is recorded under testdata/golden, to detect any regression in encoding.
+// A new map is built on every call, so the messages may be modified freely.
+func goldenMessages(t testing.TB) map[string]*DhcpMessage {
+	clientId := func() Option { return &ClientIdOption{Duid: placeholderClientDuid()} }
+	serverId := func() Option { return &ServerIdOption{Duid: placeholderServerDuid()} }
+	tid := [3]byte{0x12, 0x34, 0x56}
+
+	//type 2 (HMAC-MD5 digest), followed by the digest filled in by SignAuth
+	authInfo := make([]byte, 1+hmacMd5Len)
+	authInfo[0] = 2
+	reconfigure := &DhcpMessage{
+		MsgType: TypeReconfigure,
+		Options: []Option{
+			clientId(),
+			serverId(),
+			&ReconfMsgOption{MsgType: byte(TypeRenew)},
+			&AuthOption{
+				Protocol:                  AuthProtocolReconfigureKey,
+				Algorithm:                 AuthAlgorithmHmacMd5,
+				RDM:                       AuthRdmMonotonic,
+				ReplayDetection:           [8]byte{0, 0, 0, 0, 0, 0, 0, 1},
+				AuthenticationInformation: authInfo,
+			},
+		},
+	}
+	if err := reconfigure.SignAuth([]byte("golden key")); err != nil {
+		t.Fatal(err)
+	}
+
+	return map[string]*DhcpMessage{
+		"solicit": {
+			MsgType:       TypeSolicit,
+			TransactionId: tid,
+			Options: []Option{
+				clientId(),
+				&ElapsedTimeOption{ElapsedTime: 100},
+				&OroOption{RequestedOptionCodes: []uint16{uint16(OptionCodeDnsServers), uint16(OptionCodeDomainList)}},
+				&IaNaOption{IAID: [4]byte{0, 0, 0, 1}},
+			},
+		},
+		"reply_ia_na": {
+			MsgType:       TypeReply,
+			TransactionId: tid,
+			Options: []Option{
+				clientId(),
+				serverId(),
+				&IaNaOption{
+					IAID: [4]byte{0, 0, 0, 1},
+					T1:   1800,
+					T2:   2880,
+					IaNaOptions: []Option{
+						&IaAddrOption{
+							Ipv6Address:       net.ParseIP("2001:db8::1"),
+							PreferredLifetime: 3600,
+							ValidLifetime:     7200,
+						},
+					},
+				},
+				&DnsServersOption{Servers: []net.IP{net.ParseIP("2001:db8::53")}},
+				&DomainSearchListOption{DomainNames: []string{"example.com"}},
+			},
+		},
+		"reply_ia_pd": {
+			MsgType:       TypeReply,
+			TransactionId: tid,
+			Options: []Option{
+				clientId(),
+				serverId(),
+				&IaPdOption{
+					IAID: [4]byte{0, 0, 0, 2},
+					T1:   1800,
+					T2:   2880,
+					IaPdOptions: []Option{
+						&IaPrefixOption{
+							PreferredLifetime: 3600,
+							ValidLifetime:     7200,
+							PrefixLength:      48,
+							Ipv6Prefix:        net.ParseIP("2001:db8:1::"),
+						},
+					},
+				},
+			},
+		},
+		"reconfigure_auth": reconfigure,
+		"advertise_vendor": {
+			MsgType:       TypeAdvertise,
+			TransactionId: tid,
+			Options: []Option{
+				clientId(),
+				serverId(),
+				&PreferenceOption{PreferenceValue: 255},
+				&VendorOptsOption{
+					EnterpriseNumber: 32473, //reserved for documentation (RFC 5612)
+					OptionData: []VendorOptsOptionData{
+						{OptionCode: 1, OptionData: []byte("config")},
+					},
+				},
+			},
+		},
+	}
+}
+
+// goldenRelayMessages is the equivalent of goldenMessages for relay messages.
+func goldenRelayMessages(t testing.TB) map[string]*DhcpRelayMessage {
+	inner := goldenMessages(t)["solicit"]
+	return map[string]*DhcpRelayMessage{
+		"relay_forward": {
+			MsgType:     TypeRelayForward,
+			HopCount:    0,
+			LinkAddress: net.ParseIP("2001:db8::1"),
+			PeerAddress: net.ParseIP("fe80::200:5eff:fe00:5301"),
+			Options: []Option{
+				&InterfaceIdOption{InterfaceId: []byte("eth0")},
+				&RelayMsgOption{DhcpRelayMessage: inner},
+			},
+		},
+	}
+}
+
+func checkGolden(t *testing.T, name string, m encoding.BinaryMarshaler, decoded encoding.BinaryUnmarshaler) {
+	data, err := m.MarshalBinary()
+	if !assert.NoError(t, err, name) {
+		return
+	}
+	path := filepath.Join("testdata", "golden", name+".bin")
+	if *updateGolden {
+		assert.NoError(t, os.WriteFile(path, data, 0644))
+	}
+	golden, err := os.ReadFile(path)
+	if !assert.NoError(t, err, name) {
+		return
+	}
+	assert.Equal(t, golden, data, name)
+
+	//decoding and re-encoding must reproduce the golden file
+	assert.NoError(t, decoded.UnmarshalBinary(golden), name)
+	data, err = decoded.(encoding.BinaryMarshaler).MarshalBinary()
+	assert.NoError(t, err, name)
+	assert.Equal(t, golden, data, name)
+}
+
+func TestGoldenMessages(t *testing.T) {
+	messages := goldenMessages(t)
+	assert.Len(t, messages, 5)
+	for name, d := range messages {
+		checkGolden(t, name, d, new(DhcpMessage))
+	}
+	for name, d := range goldenRelayMessages(t) {
+		checkGolden(t, name, d, new(DhcpRelayMessage))
+	}
+}
+
+func TestGoldenMessages_noAliasing(t *testing.T) {
+	messages := make(map[string]Message)
+	for name, d := range goldenMessages(t) {
+		messages[name] = d
+	}
+	for name, d := range goldenRelayMessages(t) {
+		messages[name] = d
+	}
+	for name, m := range messages {
+		data, err := m.MarshalBinary()
+		assert.NoError(t, err)
+		buf := append([]byte{}, data...)
+		decoded, err := UnmarshalBinaryMessage(buf)
+		assert.NoError(t, err)
+
+		//the decoded message must not change when the buffer is reused
+		for i := range buf {
+			buf[i] = 0
+		}
+		actual, err := decoded.MarshalBinary()
+		assert.NoError(t, err, name)
+		assert.Equal(t, data, actual, name)
+	}
+}
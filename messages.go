@@ -1,8 +1,16 @@
 package dhcpv6
 
 import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding"
 	"encoding/binary"
+	"encoding/hex"
 	"net"
+	"sort"
+	"sync"
+	"time"
 )
 
 type DhcpMessageType byte
@@ -22,8 +30,90 @@ const (
 	TypeInformationRequest DhcpMessageType = 11
 	TypeRelayForward       DhcpMessageType = 12
 	TypeRelayReply         DhcpMessageType = 13
+
+	//leasequery message types (RFC 5007, RFC 5460, RFC 7653)
+	TypeLeasequery       DhcpMessageType = 14
+	TypeLeasequeryReply  DhcpMessageType = 15
+	TypeLeasequeryDone   DhcpMessageType = 16
+	TypeLeasequeryData   DhcpMessageType = 17
+	TypeActiveLeasequery DhcpMessageType = 22
+	TypeStartTls         DhcpMessageType = 23
 )
 
+// knownMessageTypes lists every message type defined by this package.
+var knownMessageTypes = []DhcpMessageType{
+	TypeSolicit,
+	TypeAdvertise,
+	TypeRequest,
+	TypeConfirm,
+	TypeRenew,
+	TypeRebind,
+	TypeReply,
+	TypeRelease,
+	TypeDecline,
+	TypeReconfigure,
+	TypeInformationRequest,
+	TypeRelayForward,
+	TypeRelayReply,
+	TypeLeasequery,
+	TypeLeasequeryReply,
+	TypeLeasequeryDone,
+	TypeLeasequeryData,
+	TypeActiveLeasequery,
+	TypeStartTls,
+}
+
+// KnownMessageTypes returns every message type defined by this package.
+func KnownMessageTypes() []DhcpMessageType {
+	return append([]DhcpMessageType(nil), knownMessageTypes...)
+}
+
+func isKnownMessageType(t DhcpMessageType) bool {
+	for _, known := range knownMessageTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Message is implemented by both message formats: client/server messages
+// (DhcpMessage) and relay agent/server messages (DhcpRelayMessage).
+type Message interface {
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+	Type() DhcpMessageType
+}
+
+// UnmarshalBinaryMessage will take the raw wire-format data and construct
+// the correct structure underneath based on the message type, returning the
+// Message interface.
+func UnmarshalBinaryMessage(data []byte) (msg Message, err error) {
+	if len(data) < 1 {
+		return nil, ErrUnexpectedEOF
+	}
+	switch DhcpMessageType(data[0]) {
+	case TypeRelayForward, TypeRelayReply:
+		msg = new(DhcpRelayMessage)
+	default:
+		msg = new(DhcpMessage)
+	}
+	err = msg.UnmarshalBinary(data)
+	return
+}
+
+// UnmarshalMessage will decode a client/server message like
+// DhcpMessage.UnmarshalBinary, also returning the number of bytes consumed
+// by the message. Anything past that was trailing zero padding.
+func UnmarshalMessage(data []byte) (*DhcpMessage, int, error) {
+	d := new(DhcpMessage)
+	padding, err := d.unmarshalBinary(data, DefaultMaxOptions)
+	if err != nil {
+		return nil, 0, err
+	}
+	return d, len(data) - padding, nil
+}
+
 // Client/Server Message Format
 type DhcpMessage struct {
 	MsgType       DhcpMessageType
@@ -31,40 +121,398 @@ type DhcpMessage struct {
 	Options       []Option
 }
 
+func (d *DhcpMessage) Type() DhcpMessageType {
+	return d.MsgType
+}
 func (d *DhcpMessage) MarshalBinary() ([]byte, error) {
 	data := make([]byte, 4, 32768)
 	data[0] = byte(d.MsgType)
 	copy(data[1:], d.TransactionId[:])
 	for _, v := range d.Options {
-		optionData, err := v.MarshalBinary()
+		var err error
+		data, err = appendOption(data, v)
 		if err != nil {
 			return nil, err
 		}
-		data = append(data, optionData...)
 	}
 	return data, nil
 }
+
+// UnmarshalBinary decodes a client/server message. Relay messages have a
+// different layout, and result in ErrInvalidType; decode them with
+// DhcpRelayMessage, or UnmarshalBinaryMessage when the type is not known.
+//
+// A message holding more than DefaultMaxOptions options in any one list
+// results in ErrTooManyOptions; use DecodeConfig for a different limit.
 func (d *DhcpMessage) UnmarshalBinary(data []byte) error {
+	_, err := d.unmarshalBinary(data, DefaultMaxOptions)
+	return err
+}
+
+// unmarshalBinary decodes the message like UnmarshalBinary, with at most max
+// top-level options (any number if max is 0), also returning the length of
+// any zero padding that followed the options.
+func (d *DhcpMessage) unmarshalBinary(data []byte, max int) (int, error) {
 	if len(data) < 4 {
-		return ErrUnexpectedEOF
+		return 0, ErrUnexpectedEOF
 	}
 	d.MsgType = DhcpMessageType(data[0])
+	if d.MsgType == TypeRelayForward || d.MsgType == TypeRelayReply {
+		return 0, ErrInvalidType
+	}
 	d.Options = make([]Option, 0, 10)
 	copy(d.TransactionId[:], data[1:4])
-	data = data[4:]
-	for len(data) > 0 {
-		if len(data) < 4 {
-			return ErrUnexpectedEOF
-		}
-		optSize := binary.BigEndian.Uint16(data[2:])
-		option, err := UnmarshalBinaryOption(data)
+	return unmarshalOptions(&d.Options, data, 4, false, max)
+}
+
+// Reencode will marshal a previously decoded (and possibly modified) message.
+// Options that were not understood when decoding are kept as UnknownOption and
+// emitted byte-for-byte as they were received, so a proxy may safely forward
+// options it does not know about.
+func (d *DhcpMessage) Reencode() ([]byte, error) {
+	return d.MarshalBinary()
+}
+
+// NewTransactionId returns a random transaction id, read from crypto/rand.
+//
+// RFC 3315 requires a fresh transaction id for every exchange, while
+// retransmissions within an exchange keep the one already used.
+func NewTransactionId() ([3]byte, error) {
+	var id [3]byte
+	_, err := rand.Read(id[:])
+	return id, err
+}
+
+// SetRandomTransactionId starts a new exchange by giving the message a fresh
+// transaction id (see NewTransactionId). It must not be called between
+// retransmissions of the same message.
+func (d *DhcpMessage) SetRandomTransactionId() error {
+	id, err := NewTransactionId()
+	if err != nil {
+		return err
+	}
+	d.TransactionId = id
+	return nil
+}
+
+// TransactionPool hands out transaction ids that are unique among the
+// transactions currently in progress, so that a client running several
+// exchanges at once (e.g. on more than one interface) can match every reply
+// to its request. It is safe for concurrent use, and the zero value is ready
+// to use.
+type TransactionPool struct {
+	mx     sync.Mutex
+	active map[[3]byte]struct{}
+}
+
+// maxAcquireAttempts bounds the random ids tried by Acquire before giving up.
+const maxAcquireAttempts = 64
+
+// Acquire returns a random transaction id (see NewTransactionId) that is not
+// held by any other transaction in the pool.
+//
+// ErrPoolExhausted is returned when every id is held, or when no free id was
+// found within a bounded number of attempts (as when the pool is nearly full).
+func (p *TransactionPool) Acquire() ([3]byte, error) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	if p.active == nil {
+		p.active = make(map[[3]byte]struct{})
+	}
+	if len(p.active) == 1<<24 {
+		return [3]byte{}, ErrPoolExhausted
+	}
+	for i := 0; i < maxAcquireAttempts; i++ {
+		id, err := NewTransactionId()
 		if err != nil {
-			return err
+			return [3]byte{}, err
+		}
+		if _, ok := p.active[id]; !ok {
+			p.active[id] = struct{}{}
+			return id, nil
 		}
-		d.Options = append(d.Options, option)
-		data = data[optSize+4:]
 	}
-	return nil
+	return [3]byte{}, ErrPoolExhausted
+}
+
+// Release returns id to the pool once its transaction has completed.
+func (p *TransactionPool) Release(id [3]byte) {
+	p.mx.Lock()
+	delete(p.active, id)
+	p.mx.Unlock()
+}
+
+// TransactionIdString returns the transaction id as lowercase hex (e.g. "a0a7a2").
+func (d *DhcpMessage) TransactionIdString() string {
+	return hex.EncodeToString(d.TransactionId[:])
+}
+
+// Project returns a copy of the message carrying only the top-level options
+// matching one of codes, in their original order. The options themselves are
+// shared with d, not copied.
+func (d *DhcpMessage) Project(codes ...OptionCode) *DhcpMessage {
+	p := &DhcpMessage{
+		MsgType:       d.MsgType,
+		TransactionId: d.TransactionId,
+		Options:       make([]Option, 0, len(codes)),
+	}
+	for _, o := range d.Options {
+		for _, code := range codes {
+			if o.Code() == code {
+				p.Options = append(p.Options, o)
+				break
+			}
+		}
+	}
+	return p
+}
+
+// GetOption returns the first top-level option matching code, or nil if the
+// message carries none.
+func (d *DhcpMessage) GetOption(code OptionCode) Option {
+	return getOption(d.Options, code)
+}
+
+// GetOptions returns every top-level option matching code, in order.
+func (d *DhcpMessage) GetOptions(code OptionCode) []Option {
+	return getOptions(d.Options, code)
+}
+
+// AddOption appends o to the options of the message.
+func (d *DhcpMessage) AddOption(o Option) {
+	d.Options = append(d.Options, o)
+}
+
+// RemoveOptions removes every top-level option matching code, returning the
+// number removed. The order of the remaining options is unchanged.
+func (d *DhcpMessage) RemoveOptions(code OptionCode) int {
+	var n int
+	d.Options, n = removeOptions(d.Options, code)
+	return n
+}
+
+// ReplaceOption puts o in place of the first top-level option with the same
+// code, removing any others, or appends o if there is none. It is intended
+// for options that may appear only once.
+func (d *DhcpMessage) ReplaceOption(o Option) {
+	d.Options = replaceOption(d.Options, o)
+}
+
+// ClientId returns the Client Identifier option, and whether it is present.
+func (d *DhcpMessage) ClientId() (*ClientIdOption, bool) {
+	o, ok := d.GetOption(OptionCodeClientId).(*ClientIdOption)
+	return o, ok
+}
+
+// ServerId returns the Server Identifier option, and whether it is present.
+func (d *DhcpMessage) ServerId() (*ServerIdOption, bool) {
+	o, ok := d.GetOption(OptionCodeServerId).(*ServerIdOption)
+	return o, ok
+}
+
+// Preference returns the Preference option, and whether it is present.
+func (d *DhcpMessage) Preference() (*PreferenceOption, bool) {
+	o, ok := d.GetOption(OptionCodePreference).(*PreferenceOption)
+	return o, ok
+}
+
+// StatusCode returns the top-level Status Code option, and whether it is
+// present.
+func (d *DhcpMessage) StatusCode() (*StatusCodeOption, bool) {
+	o, ok := d.GetOption(OptionCodeStatusCode).(*StatusCodeOption)
+	return o, ok
+}
+
+// IaNa returns every IA_NA option, in order.
+func (d *DhcpMessage) IaNa() []*IaNaOption {
+	var ias []*IaNaOption
+	for _, o := range d.Options {
+		if ia, ok := o.(*IaNaOption); ok {
+			ias = append(ias, ia)
+		}
+	}
+	return ias
+}
+
+// IaTa returns every IA_TA option, in order.
+func (d *DhcpMessage) IaTa() []*IaTaOption {
+	var ias []*IaTaOption
+	for _, o := range d.Options {
+		if ia, ok := o.(*IaTaOption); ok {
+			ias = append(ias, ia)
+		}
+	}
+	return ias
+}
+
+// IaPd returns every IA_PD option, in order.
+func (d *DhcpMessage) IaPd() []*IaPdOption {
+	var ias []*IaPdOption
+	for _, o := range d.Options {
+		if ia, ok := o.(*IaPdOption); ok {
+			ias = append(ias, ia)
+		}
+	}
+	return ias
+}
+
+// Hash returns the SHA-256 of the message in a canonical form, so that
+// retransmissions of the same message can be recognized.
+//
+// The Elapsed Time option is left out, as a client updates it on every
+// retransmission, and the remaining top-level options are ordered by code
+// (options sharing a code keep their relative order). Nested options are
+// hashed as they are.
+func (d *DhcpMessage) Hash() ([32]byte, error) {
+	c := &DhcpMessage{
+		MsgType:       d.MsgType,
+		TransactionId: d.TransactionId,
+		Options:       make([]Option, 0, len(d.Options)),
+	}
+	for _, o := range d.Options {
+		if o.Code() != OptionCodeElapsedTime {
+			c.Options = append(c.Options, o)
+		}
+	}
+	sort.SliceStable(c.Options, func(i, j int) bool {
+		return c.Options[i].Code() < c.Options[j].Code()
+	})
+	data, err := c.MarshalBinary()
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return sha256.Sum256(data), nil
+}
+
+// StripUnknownOptions removes every top-level UnknownOption from the message,
+// returning the number of options removed.
+func (d *DhcpMessage) StripUnknownOptions() int {
+	var n int
+	d.Options, n = stripUnknownOptions(d.Options, false)
+	return n
+}
+
+// StripUnknownOptionsRecursive behaves like StripUnknownOptions, but also
+// removes UnknownOptions nested inside of other options (IA_NA, IA_ADDR, etc..).
+func (d *DhcpMessage) StripUnknownOptionsRecursive() int {
+	var n int
+	d.Options, n = stripUnknownOptions(d.Options, true)
+	return n
+}
+
+func stripUnknownOptions(options []Option, recursive bool) ([]Option, int) {
+	n := 0
+	kept := options[:0]
+	for _, o := range options {
+		if _, ok := o.(*UnknownOption); ok {
+			n++
+			continue
+		}
+		if sub := subOptions(o); recursive && sub != nil {
+			var removed int
+			*sub, removed = stripUnknownOptions(*sub, true)
+			n += removed
+		}
+		kept = append(kept, o)
+	}
+	return kept, n
+}
+
+// CoalesceOro merges every Option Request option of the message into the
+// first, removing the others. Each requested code is kept once, in the order
+// it was first requested.
+func (d *DhcpMessage) CoalesceOro() {
+	var first *OroOption
+	var codes []uint16
+	seen := make(map[uint16]bool)
+	kept := d.Options[:0]
+	for _, o := range d.Options {
+		oro, ok := o.(*OroOption)
+		if !ok || first == nil {
+			kept = append(kept, o)
+		}
+		if !ok {
+			continue
+		}
+		if first == nil {
+			first = oro
+		}
+		for _, code := range oro.RequestedOptionCodes {
+			if !seen[code] {
+				seen[code] = true
+				codes = append(codes, code)
+			}
+		}
+	}
+	d.Options = kept
+	if first != nil {
+		first.RequestedOptionCodes = codes
+	}
+}
+
+// EnsureElapsedTime sets the Elapsed Time option to the time elapsed since the
+// start of the exchange (see ElapsedTimeSince). If the message does not carry
+// one yet, it is inserted near the front, following any leading Client and
+// Server Id options.
+func (d *DhcpMessage) EnsureElapsedTime(since time.Time) {
+	elapsed := ElapsedTimeSince(since)
+	for i, o := range d.Options {
+		if _, ok := o.(*ElapsedTimeOption); ok {
+			d.Options[i] = elapsed
+			return
+		}
+	}
+	pos := 0
+	for pos < len(d.Options) {
+		code := d.Options[pos].Code()
+		if code != OptionCodeClientId && code != OptionCodeServerId {
+			break
+		}
+		pos++
+	}
+	d.Options = append(d.Options, nil)
+	copy(d.Options[pos+1:], d.Options[pos:])
+	d.Options[pos] = elapsed
+}
+
+// CanonicalizeAddresses converts every address carried by the message's
+// options (including nested options) to its 16-byte form, so they may be
+// compared or logged consistently.
+func (d *DhcpMessage) CanonicalizeAddresses() {
+	canonicalizeAddresses(d.Options)
+}
+
+func canonicalizeAddresses(options []Option) {
+	for _, o := range options {
+		switch v := o.(type) {
+		case *IaAddrOption:
+			v.Ipv6Address = canonicalIP(v.Ipv6Address)
+		case *IaPrefixOption:
+			v.Ipv6Prefix = canonicalIP(v.Ipv6Prefix)
+		case *UnicastOption:
+			v.ServerAddress = canonicalIP(v.ServerAddress)
+		case *NextHopOption:
+			v.NextHop = canonicalIP(v.NextHop)
+		case *RtPrefixOption:
+			v.Prefix = canonicalIP(v.Prefix)
+		case *DnsServersOption:
+			for i := range v.Servers {
+				v.Servers[i] = canonicalIP(v.Servers[i])
+			}
+		}
+		if sub := subOptions(o); sub != nil {
+			canonicalizeAddresses(*sub)
+		}
+	}
+}
+
+// canonicalIP returns the 16-byte form of ip, or ip unchanged if it is not a
+// valid address.
+func canonicalIP(ip net.IP) net.IP {
+	if ip16 := ip.To16(); ip16 != nil {
+		return ip16
+	}
+	return ip
 }
 
 // Relay Agent/Server Message Format
@@ -76,12 +524,15 @@ type DhcpRelayMessage struct {
 	Options     []Option
 }
 
+func (d *DhcpRelayMessage) Type() DhcpMessageType {
+	return d.MsgType
+}
 func (d *DhcpRelayMessage) MarshalBinary() ([]byte, error) {
-	if len(d.LinkAddress) != 16 {
-		return nil, ErrInvalidIpv6Address
+	if err := checkIpv6Address(d.LinkAddress); err != nil {
+		return nil, err
 	}
-	if len(d.PeerAddress) != 16 {
-		return nil, ErrInvalidIpv6Address
+	if err := checkIpv6Address(d.PeerAddress); err != nil {
+		return nil, err
 	}
 	data := make([]byte, 34, 32768)
 	data[0] = byte(d.MsgType)
@@ -89,35 +540,166 @@ func (d *DhcpRelayMessage) MarshalBinary() ([]byte, error) {
 	copy(data[2:], d.LinkAddress)
 	copy(data[18:], d.PeerAddress)
 	for _, v := range d.Options {
-		optionData, err := v.MarshalBinary()
+		var err error
+		data, err = appendOption(data, v)
 		if err != nil {
 			return nil, err
 		}
-		data = append(data, optionData...)
 	}
 	return data, nil
 }
+
+// UnmarshalBinary decodes a relay message. As for DhcpMessage.UnmarshalBinary,
+// more than DefaultMaxOptions options in any one list results in
+// ErrTooManyOptions.
 func (d *DhcpRelayMessage) UnmarshalBinary(data []byte) error {
+	_, err := d.unmarshalBinary(data, DefaultMaxOptions)
+	return err
+}
+
+// unmarshalBinary decodes the message like UnmarshalBinary, with at most max
+// top-level options (any number if max is 0), also returning the length of
+// any zero padding that followed the options.
+func (d *DhcpRelayMessage) unmarshalBinary(data []byte, max int) (int, error) {
+	return d.unmarshalRelay(data, false, max)
+}
+
+// unmarshalRelay decodes the message like unmarshalBinary. When shallow, the
+// relayed message is not decoded, and is left in the RawInner of the Relay
+// Message option instead.
+func (d *DhcpRelayMessage) unmarshalRelay(data []byte, shallow bool, max int) (int, error) {
 	if len(data) < 34 {
-		return ErrUnexpectedEOF
+		return 0, ErrUnexpectedEOF
 	}
 	d.MsgType = DhcpMessageType(data[0])
 	d.HopCount = data[1]
-	d.LinkAddress = data[2:18]
-	d.PeerAddress = data[18:34]
-	data = data[34:]
+	d.LinkAddress = net.IP(cloneBytes(data[2:18]))
+	d.PeerAddress = net.IP(cloneBytes(data[18:34]))
 	d.Options = nil
-	for len(data) > 0 {
-		if len(data) < 4 {
-			return ErrUnexpectedEOF
+	return unmarshalOptions(&d.Options, data, 34, shallow, max)
+}
+
+// GetOption returns the first top-level option matching code, or nil if the
+// message carries none.
+func (d *DhcpRelayMessage) GetOption(code OptionCode) Option {
+	return getOption(d.Options, code)
+}
+
+// GetOptions returns every top-level option matching code, in order.
+func (d *DhcpRelayMessage) GetOptions(code OptionCode) []Option {
+	return getOptions(d.Options, code)
+}
+
+// AddOption appends o to the options of the message.
+func (d *DhcpRelayMessage) AddOption(o Option) {
+	d.Options = append(d.Options, o)
+}
+
+// RemoveOptions behaves like DhcpMessage.RemoveOptions.
+func (d *DhcpRelayMessage) RemoveOptions(code OptionCode) int {
+	var n int
+	d.Options, n = removeOptions(d.Options, code)
+	return n
+}
+
+// ReplaceOption behaves like DhcpMessage.ReplaceOption, e.g. to overwrite the
+// Interface-Id option of a relay message.
+func (d *DhcpRelayMessage) ReplaceOption(o Option) {
+	d.Options = replaceOption(d.Options, o)
+}
+
+func getOption(options []Option, code OptionCode) Option {
+	for _, o := range options {
+		if o.Code() == code {
+			return o
+		}
+	}
+	return nil
+}
+
+func getOptions(options []Option, code OptionCode) []Option {
+	var matches []Option
+	for _, o := range options {
+		if o.Code() == code {
+			matches = append(matches, o)
+		}
+	}
+	return matches
+}
+
+func removeOptions(options []Option, code OptionCode) ([]Option, int) {
+	kept := options[:0]
+	for _, o := range options {
+		if o.Code() != code {
+			kept = append(kept, o)
+		}
+	}
+	return kept, len(options) - len(kept)
+}
+
+func replaceOption(options []Option, o Option) []Option {
+	for i, existing := range options {
+		if existing.Code() == o.Code() {
+			rest, _ := removeOptions(options[i+1:], o.Code())
+			options[i] = o
+			return options[:i+1+len(rest)]
 		}
-		optSize := binary.BigEndian.Uint16(data[2:])
-		option, err := UnmarshalBinaryOption(data)
+	}
+	return append(options, o)
+}
+
+// unmarshalOptions will decode the options of a message, starting at offset,
+// appending them to options. Failures are reported as a *DecodeError.
+//
+// A Domain Search List that was split across consecutive options is joined
+// back into a single option.
+//
+// DHCPv6 has no padding, but some implementations append zero octets to their
+// messages. Such trailing zeros are ignored, and their length returned.
+//
+// When shallow, a Relay Message option is not decoded, and carries a copy of
+// the relayed message in its RawInner.
+//
+// More than max options (unless max is 0) result in ErrTooManyOptions, before
+// the excess option is decoded. Lists nested within the options are limited
+// by the decoding of each option instead (see DecodeConfig.MaxOptions).
+func unmarshalOptions(options *[]Option, data []byte, offset int, shallow bool, max int) (int, error) {
+	//everything from the last non-zero octet on is padding, should an option
+	//start there
+	padding := len(bytes.TrimRight(data, "\x00"))
+	for n := 0; offset < len(data); n++ {
+		if offset >= padding {
+			return len(data) - offset, nil
+		}
+		if len(data)-offset < 4 {
+			return 0, &DecodeError{Offset: offset, Err: ErrUnexpectedEOF}
+		}
+		code := OptionCode(binary.BigEndian.Uint16(data[offset:]))
+		if max > 0 && n == max {
+			return 0, &DecodeError{OptionCode: code, Offset: offset, Err: ErrTooManyOptions}
+		}
+		optSize := int(binary.BigEndian.Uint16(data[offset+2:]))
+		if len(data)-offset < optSize+4 {
+			return 0, &DecodeError{OptionCode: code, Offset: offset, Err: ErrUnexpectedEOF}
+		}
+		//bound the option (including its capacity) to its own bytes
+		end := offset + optSize + 4
+		if shallow && code == OptionCodeRelayMsg {
+			*options = append(*options, &RelayMsgOption{RawInner: cloneBytes(data[offset+4 : end])})
+			offset = end
+			continue
+		}
+		option, err := UnmarshalBinaryOption(data[offset:end:end])
 		if err != nil {
-			return err
+			return 0, &DecodeError{OptionCode: code, Offset: offset, Err: err}
 		}
-		d.Options = append(d.Options, option)
-		data = data[optSize+4:]
+		offset += optSize + 4
+		if list, ok := option.(*DomainSearchListOption); ok && len(*options) > 0 {
+			if prev, ok := (*options)[len(*options)-1].(*DomainSearchListOption); ok && prev.concatenate(list) {
+				continue
+			}
+		}
+		*options = append(*options, option)
 	}
-	return nil
+	return 0, nil
 }
@@ -1,8 +1,15 @@
 package dhcpv6
 
 import (
+	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"sync"
+	"testing"
+	"time"
 )
 
 // Create a DHCPv6 Solicit message from scratch and print it
@@ -39,3 +46,500 @@ func ExampleDhcpMessage_MarshalBinary() {
 	fmt.Println(hex.EncodeToString(data))
 	//output: 01a0a7a2000e00000003000cafaaaca30000000000000000000600060017001800380001000e00020000ab11aca2a8afaea3a3af000800020000
 }
+
+func TestDhcpMessage_UnmarshalBinary(t *testing.T) {
+	data := []byte{
+		byte(TypeSolicit), 0x01, 0x02, 0x03,
+		0x03, 0xe8, 0x00, 0x02, 0xaa, 0xbb, //unknown option 1000
+		0x00, 0x08, 0x00, 0x02, 0x00, 0x64, //elapsed time
+	}
+	d := new(DhcpMessage)
+	assert.NoError(t, d.UnmarshalBinary(data))
+	if assert.Len(t, d.Options, 2) {
+		unknown := d.Options[0].(*UnknownOption)
+		assert.Equal(t, []byte{0xaa, 0xbb}, unknown.OptionData)
+		assert.Equal(t, 2, cap(unknown.OptionData), "must not reach into the next option")
+		assert.Equal(t, &ElapsedTimeOption{ElapsedTime: 100}, d.Options[1])
+
+		unknown.OptionData = append(unknown.OptionData, 0xcc)
+		assert.Equal(t, byte(0x00), data[10], "growing the unknown option leaves the buffer intact")
+	}
+
+	//an unknown option claiming more than the message holds
+	data[7] = 0x0c
+	assert.ErrorIs(t, d.UnmarshalBinary(data), ErrUnexpectedEOF)
+}
+
+func TestDhcpMessage_UnmarshalBinary_relay(t *testing.T) {
+	data, err := goldenRelayMessages(t)["relay_forward"].MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, ErrInvalidType, new(DhcpMessage).UnmarshalBinary(data))
+	data[0] = byte(TypeRelayReply)
+	assert.Equal(t, ErrInvalidType, new(DhcpMessage).UnmarshalBinary(data))
+
+	msg, err := UnmarshalBinaryMessage(data)
+	assert.NoError(t, err)
+	assert.IsType(t, &DhcpRelayMessage{}, msg)
+}
+
+func TestUnmarshalMessage(t *testing.T) {
+	data, err := exampleSolicit().MarshalBinary()
+	assert.NoError(t, err)
+	d, n, err := UnmarshalMessage(data)
+	assert.NoError(t, err)
+	assert.Equal(t, len(data), n)
+	assert.Equal(t, TypeSolicit, d.MsgType)
+
+	padded := append(append([]byte{}, data...), 0, 0, 0)
+	d, n, err = UnmarshalMessage(padded)
+	assert.NoError(t, err)
+	assert.Equal(t, len(data), n, "trailing padding is not consumed")
+	remarshaled, err := d.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, data, remarshaled)
+
+	_, _, err = UnmarshalMessage(data[:len(data)-1])
+	assert.ErrorIs(t, err, ErrUnexpectedEOF)
+}
+
+func TestDhcpMessage_CoalesceOro(t *testing.T) {
+	d := &DhcpMessage{
+		MsgType: TypeSolicit,
+		Options: []Option{
+			&OroOption{RequestedOptionCodes: []uint16{23, 24, 23}},
+			&ElapsedTimeOption{},
+			&OroOption{RequestedOptionCodes: []uint16{56, 24}},
+		},
+	}
+	d.CoalesceOro()
+	assert.Equal(t, []Option{
+		&OroOption{RequestedOptionCodes: []uint16{23, 24, 56}},
+		&ElapsedTimeOption{},
+	}, d.Options)
+
+	d = &DhcpMessage{Options: []Option{&ElapsedTimeOption{}}}
+	d.CoalesceOro()
+	assert.Equal(t, []Option{&ElapsedTimeOption{}}, d.Options, "no ORO")
+}
+
+func TestDhcpMessage_StripUnknownOptions(t *testing.T) {
+	newMessage := func() *DhcpMessage {
+		return &DhcpMessage{
+			MsgType: TypeReply,
+			Options: []Option{
+				&UnknownOption{OptionCode: 1000, OptionData: []byte{0x01}},
+				&RapidCommitOption{},
+				&IaNaOption{
+					IaNaOptions: []Option{
+						&UnknownOption{OptionCode: 1001},
+						&StatusCodeOption{},
+					},
+				},
+				&UnknownOption{OptionCode: 1002},
+			},
+		}
+	}
+
+	d := newMessage()
+	assert.Equal(t, 2, d.StripUnknownOptions())
+	assert.Len(t, d.Options, 2)
+	assert.IsType(t, &RapidCommitOption{}, d.Options[0])
+	assert.Len(t, d.Options[1].(*IaNaOption).IaNaOptions, 2)
+
+	d = newMessage()
+	assert.Equal(t, 3, d.StripUnknownOptionsRecursive())
+	assert.Len(t, d.Options, 2)
+	assert.Equal(t, []Option{&StatusCodeOption{}}, d.Options[1].(*IaNaOption).IaNaOptions)
+}
+
+func TestDhcpMessage_TransactionIdString(t *testing.T) {
+	d := DhcpMessage{TransactionId: [3]byte{0xa0, 0xa7, 0xa2}}
+	assert.Equal(t, "a0a7a2", d.TransactionIdString())
+	d.TransactionId = [3]byte{0x00, 0x01, 0x0f}
+	assert.Equal(t, "00010f", d.TransactionIdString())
+}
+
+func TestDhcpMessage_Reencode(t *testing.T) {
+	unknown := []byte{0xfe, 0xed, 0x00, 0x05, 0xde, 0xad, 0xbe, 0xef, 0x00}
+	data := append([]byte{byte(TypeSolicit), 0xa0, 0xa7, 0xa2}, unknown...)
+	data = append(data, 0x00, 0x08, 0x00, 0x02, 0x00, 0x00)
+
+	d := new(DhcpMessage)
+	assert.NoError(t, d.UnmarshalBinary(data))
+	d.Options[1].(*ElapsedTimeOption).ElapsedTime = 100
+
+	actual, err := d.Reencode()
+	assert.NoError(t, err)
+	assert.Equal(t, unknown, actual[4:4+len(unknown)])
+	assert.Equal(t, []byte{0x00, 0x08, 0x00, 0x02, 0x00, 0x64}, actual[4+len(unknown):])
+}
+
+// exampleSolicit returns the message from ExampleDhcpMessage_MarshalBinary.
+func exampleSolicit() *DhcpMessage {
+	return &DhcpMessage{
+		MsgType:       TypeSolicit,
+		TransactionId: [3]byte{0xa0, 0xa7, 0xa2},
+		Options: []Option{
+			&RapidCommitOption{},
+			&IaNaOption{IAID: [4]byte{0xaf, 0xaa, 0xac, 0xa3}},
+			&OroOption{RequestedOptionCodes: []uint16{23, 24, 56}},
+			&ClientIdOption{
+				Duid: &EnDuid{
+					EnterpriseNumber: 43793,
+					Identifier:       []byte{0xac, 0xa2, 0xa8, 0xaf, 0xae, 0xa3, 0xa3, 0xaf},
+				},
+			},
+			&ElapsedTimeOption{},
+		},
+	}
+}
+
+// largeReply returns a Reply carrying many IA_NA options, each with a few
+// addresses.
+func largeReply() *DhcpMessage {
+	d := &DhcpMessage{
+		MsgType:       TypeReply,
+		TransactionId: [3]byte{0xa0, 0xa7, 0xa2},
+		Options: []Option{
+			&ClientIdOption{Duid: &LlDuid{HardwareType: 1, LlAddress: []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}}},
+			&ServerIdOption{Duid: &LlDuid{HardwareType: 1, LlAddress: []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x66}}},
+		},
+	}
+	for i := 0; i < 32; i++ {
+		ia := &IaNaOption{IAID: [4]byte{0, 0, 0, byte(i)}, T1: 1800, T2: 2880}
+		for j := 0; j < 4; j++ {
+			addr := net.ParseIP("2001:db8::")
+			addr[14], addr[15] = byte(i), byte(j)
+			ia.IaNaOptions = append(ia.IaNaOptions, &IaAddrOption{
+				Ipv6Address:       addr,
+				PreferredLifetime: 3600,
+				ValidLifetime:     7200,
+			})
+		}
+		d.Options = append(d.Options, ia)
+	}
+	return d
+}
+
+func BenchmarkDhcpMessage_MarshalBinary(b *testing.B) {
+	for name, d := range map[string]*DhcpMessage{"example": exampleSolicit(), "large": largeReply()} {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				d.MarshalBinary()
+			}
+		})
+	}
+}
+
+func BenchmarkDhcpMessage_UnmarshalBinary(b *testing.B) {
+	for name, d := range map[string]*DhcpMessage{"example": exampleSolicit(), "large": largeReply()} {
+		data, err := d.MarshalBinary()
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				new(DhcpMessage).UnmarshalBinary(data)
+			}
+		})
+	}
+}
+
+func TestDhcpMessage_CanonicalizeAddresses(t *testing.T) {
+	d := &DhcpMessage{
+		MsgType: TypeReply,
+		Options: []Option{
+			&IaNaOption{
+				IaNaOptions: []Option{
+					&IaAddrOption{Ipv6Address: net.IPv4(192, 0, 2, 1).To4()},
+				},
+			},
+			&UnicastOption{ServerAddress: net.ParseIP("2001:db8::1")},
+			&DnsServersOption{Servers: []net.IP{net.IPv4(192, 0, 2, 53).To4()}},
+		},
+	}
+	d.CanonicalizeAddresses()
+	addr := d.Options[0].(*IaNaOption).IaNaOptions[0].(*IaAddrOption).Ipv6Address
+	assert.Len(t, addr, 16)
+	assert.True(t, net.IPv4(192, 0, 2, 1).Equal(addr))
+	assert.Len(t, d.Options[1].(*UnicastOption).ServerAddress, 16)
+	assert.Len(t, d.Options[2].(*DnsServersOption).Servers[0], 16)
+
+	data, err := d.MarshalBinary()
+	assert.NoError(t, err)
+	decoded := new(DhcpMessage)
+	assert.NoError(t, decoded.UnmarshalBinary(data))
+	decoded.CanonicalizeAddresses()
+	assert.Len(t, decoded.Options[0].(*IaNaOption).IaNaOptions[0].(*IaAddrOption).Ipv6Address, 16)
+}
+
+func TestDhcpMessage_EnsureElapsedTime(t *testing.T) {
+	defer func(now func() time.Time) { Now = now }(Now)
+	start := time.Date(2015, time.June, 1, 12, 0, 0, 0, time.UTC)
+	Now = func() time.Time { return start.Add(2 * time.Second) }
+
+	clientId := &ClientIdOption{Duid: &LlDuid{HardwareType: 1, LlAddress: []byte{0x01}}}
+	d := &DhcpMessage{
+		MsgType: TypeSolicit,
+		Options: []Option{clientId, &IaNaOption{}},
+	}
+	d.EnsureElapsedTime(start)
+	assert.Len(t, d.Options, 3)
+	assert.Equal(t, clientId, d.Options[0])
+	assert.Equal(t, &ElapsedTimeOption{ElapsedTime: 200}, d.Options[1])
+
+	Now = func() time.Time { return start.Add(3 * time.Second) }
+	d.EnsureElapsedTime(start)
+	assert.Len(t, d.Options, 3, "existing option is updated")
+	assert.Equal(t, &ElapsedTimeOption{ElapsedTime: 300}, d.Options[1])
+
+	d = &DhcpMessage{MsgType: TypeSolicit}
+	d.EnsureElapsedTime(start)
+	assert.Equal(t, []Option{&ElapsedTimeOption{ElapsedTime: 300}}, d.Options)
+}
+
+func TestDhcpMessage_Project(t *testing.T) {
+	d := exampleSolicit()
+	p := d.Project(OptionCodeIaNa)
+	assert.Equal(t, d.MsgType, p.MsgType)
+	assert.Equal(t, d.TransactionId, p.TransactionId)
+	assert.Equal(t, []Option{d.Options[1]}, p.Options)
+
+	p = d.Project(OptionCodeElapsedTime, OptionCodeClientId, OptionCodeIaNa)
+	assert.Equal(t, []Option{d.Options[1], d.Options[3], d.Options[4]}, p.Options)
+	assert.Len(t, d.Options, 5, "original is untouched")
+
+	assert.Empty(t, d.Project(OptionCodeServerId).Options)
+}
+
+func TestDhcpMessage_Hash(t *testing.T) {
+	first := exampleSolicit()
+	retransmit := exampleSolicit()
+	retransmit.Options[4] = &ElapsedTimeOption{ElapsedTime: 150}
+	h1, err := first.Hash()
+	assert.NoError(t, err)
+	h2, err := retransmit.Hash()
+	assert.NoError(t, err)
+	assert.Equal(t, h1, h2, "retransmissions differ only in elapsed time")
+
+	//option order does not matter
+	reordered := exampleSolicit()
+	reordered.Options[0], reordered.Options[3] = reordered.Options[3], reordered.Options[0]
+	h2, err = reordered.Hash()
+	assert.NoError(t, err)
+	assert.Equal(t, h1, h2)
+	assert.IsType(t, &ClientIdOption{}, reordered.Options[0], "original is untouched")
+
+	other := exampleSolicit()
+	other.TransactionId[2]++
+	h2, err = other.Hash()
+	assert.NoError(t, err)
+	assert.NotEqual(t, h1, h2)
+}
+
+func TestDhcpRelayMessage_MarshalBinary(t *testing.T) {
+	d := &DhcpRelayMessage{MsgType: TypeRelayForward, PeerAddress: net.ParseIP("fe80::1")}
+	_, err := d.MarshalBinary()
+	assert.Equal(t, ErrIpv6AddressNotSet, err)
+	d.LinkAddress = net.IPv4(192, 0, 2, 1).To4()
+	_, err = d.MarshalBinary()
+	assert.Equal(t, ErrInvalidIpv6Address, err)
+	d.LinkAddress = net.ParseIP("2001:db8::1")
+	d.PeerAddress = nil
+	_, err = d.MarshalBinary()
+	assert.Equal(t, ErrIpv6AddressNotSet, err)
+}
+
+func TestDhcpMessage_GetOption(t *testing.T) {
+	d := exampleSolicit()
+	assert.Equal(t, d.Options[1], d.GetOption(OptionCodeIaNa))
+	assert.Nil(t, d.GetOption(OptionCodeServerId))
+	assert.NotNil(t, d.GetOption(OptionCodeRapidCommit))
+
+	d.Options = append(d.Options, &IaNaOption{IAID: [4]byte{1}})
+	assert.Equal(t, []Option{d.Options[1], d.Options[5]}, d.GetOptions(OptionCodeIaNa))
+	assert.Empty(t, d.GetOptions(OptionCodeServerId))
+}
+
+func TestDhcpRelayMessage_GetOption(t *testing.T) {
+	d := relayMessage(0, exampleSolicit())
+	assert.Equal(t, d.Options[1], d.GetOption(OptionCodeRelayMsg))
+	assert.Nil(t, d.GetOption(OptionCodeClientId))
+	assert.Equal(t, []Option{d.Options[0]}, d.GetOptions(OptionCodeInterfaceId))
+}
+
+func TestDhcpMessage_typedAccessors(t *testing.T) {
+	d := exampleSolicit()
+	clientId, ok := d.ClientId()
+	assert.True(t, ok)
+	assert.Equal(t, d.Options[3], clientId)
+	_, ok = d.ServerId()
+	assert.False(t, ok)
+	_, ok = d.Preference()
+	assert.False(t, ok)
+	_, ok = d.StatusCode()
+	assert.False(t, ok)
+	assert.Equal(t, []*IaNaOption{d.Options[1].(*IaNaOption)}, d.IaNa())
+	assert.Empty(t, d.IaTa())
+	assert.Empty(t, d.IaPd())
+
+	d = &DhcpMessage{
+		MsgType: TypeAdvertise,
+		Options: []Option{
+			&ServerIdOption{Duid: placeholderServerDuid()},
+			&PreferenceOption{},
+			&StatusCodeOption{StatusCode: NoAddrsAvail},
+			&IaTaOption{IAID: [4]byte{1}},
+			&IaPdOption{IAID: [4]byte{2}},
+			&IaPdOption{IAID: [4]byte{3}},
+		},
+	}
+	serverId, ok := d.ServerId()
+	assert.True(t, ok)
+	assert.Equal(t, placeholderServerDuid(), serverId.Duid)
+	preference, ok := d.Preference()
+	assert.True(t, ok, "present, even though zero")
+	assert.Equal(t, byte(0), preference.PreferenceValue)
+	status, ok := d.StatusCode()
+	assert.True(t, ok)
+	assert.Equal(t, NoAddrsAvail, status.StatusCode)
+	assert.Len(t, d.IaTa(), 1)
+	assert.Equal(t, []*IaPdOption{d.Options[4].(*IaPdOption), d.Options[5].(*IaPdOption)}, d.IaPd())
+}
+
+func TestNewTransactionId(t *testing.T) {
+	seen := make(map[[3]byte]bool)
+	for i := 0; i < 100; i++ {
+		id, err := NewTransactionId()
+		assert.NoError(t, err)
+		seen[id] = true
+	}
+	//collisions among 100 random 24-bit ids are possible, but a handful at most
+	assert.Greater(t, len(seen), 95)
+
+	d := exampleSolicit()
+	before := d.TransactionId
+	changed := false
+	for i := 0; i < 5 && !changed; i++ {
+		assert.NoError(t, d.SetRandomTransactionId())
+		changed = d.TransactionId != before
+	}
+	assert.True(t, changed)
+}
+
+func TestTransactionPool(t *testing.T) {
+	var pool TransactionPool
+	const workers, perWorker = 8, 500
+	ids := make(chan [3]byte, workers*perWorker)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perWorker; j++ {
+				id, err := pool.Acquire()
+				assert.NoError(t, err)
+				ids <- id
+			}
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := make(map[[3]byte]bool)
+	for id := range ids {
+		assert.False(t, seen[id], "id %x handed out twice", id)
+		seen[id] = true
+	}
+	assert.Len(t, seen, workers*perWorker)
+
+	for id := range seen {
+		pool.Release(id)
+	}
+	assert.Empty(t, pool.active)
+}
+
+func TestTransactionPool_Acquire_exhausted(t *testing.T) {
+	if testing.Short() {
+		t.Skip("fills the pool with every transaction id")
+	}
+	pool := TransactionPool{active: make(map[[3]byte]struct{}, 1<<24)}
+	for i := 0; i < 1<<24; i++ {
+		pool.active[[3]byte{byte(i >> 16), byte(i >> 8), byte(i)}] = struct{}{}
+	}
+	_, err := pool.Acquire()
+	assert.Equal(t, ErrPoolExhausted, err)
+}
+
+func TestDhcpMessage_UnmarshalBinary_optionLimit(t *testing.T) {
+	//as many empty Rapid Commit options as fit in a message
+	data := []byte{byte(TypeSolicit), 0x01, 0x02, 0x03}
+	for len(data)+4 <= 65535 {
+		data = append(data, 0x00, 0x0e, 0x00, 0x00)
+	}
+	var err error
+	d := new(DhcpMessage)
+	allocs := testing.AllocsPerRun(1, func() {
+		err = d.UnmarshalBinary(data)
+	})
+	assert.True(t, errors.Is(err, ErrTooManyOptions))
+	assert.True(t, errors.Is(err, ErrInvalidData))
+	var decodeErr *DecodeError
+	if assert.True(t, errors.As(err, &decodeErr)) {
+		assert.Equal(t, 4+4*DefaultMaxOptions, decodeErr.Offset)
+	}
+	assert.Len(t, d.Options, DefaultMaxOptions, "decoding stops at the limit")
+	assert.Less(t, allocs, float64(4*DefaultMaxOptions))
+
+	_, err = UnmarshalBinaryMessage(data)
+	assert.True(t, errors.Is(err, ErrTooManyOptions))
+
+	//the same, nested within an IA_NA
+	ia := []byte{0x00, 0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+	for len(ia)+4 <= 65535 {
+		ia = append(ia, 0x00, 0x0e, 0x00, 0x00)
+	}
+	binary.BigEndian.PutUint16(ia[2:], uint16(len(ia)-4))
+	assert.Equal(t, ErrTooManyOptions, new(IaNaOption).UnmarshalBinary(ia))
+
+	//and within a relayed message
+	relay := relayMessage(0, nil)
+	relay.Options[1] = &RelayMsgOption{RawInner: data[:65535-34-4-8]}
+	err = new(DhcpRelayMessage).UnmarshalBinary(mustMarshal(t, relay))
+	assert.True(t, errors.Is(err, ErrTooManyOptions))
+}
+
+func TestDhcpMessage_RemoveOptions(t *testing.T) {
+	d := exampleSolicit()
+	d.AddOption(&IaNaOption{IAID: [4]byte{0, 0, 0, 2}})
+	assert.Len(t, d.Options, 6)
+
+	assert.Equal(t, 2, d.RemoveOptions(OptionCodeIaNa))
+	assert.Equal(t, 0, d.RemoveOptions(OptionCodeIaNa))
+	codes := make([]OptionCode, len(d.Options))
+	for i, o := range d.Options {
+		codes[i] = o.Code()
+	}
+	assert.Equal(t, []OptionCode{OptionCodeRapidCommit, OptionCodeOro, OptionCodeClientId, OptionCodeElapsedTime}, codes)
+}
+
+func TestDhcpMessage_ReplaceOption(t *testing.T) {
+	d := exampleSolicit()
+	d.AddOption(&ElapsedTimeOption{ElapsedTime: 1})
+	d.ReplaceOption(&ElapsedTimeOption{ElapsedTime: 100})
+	assert.Len(t, d.Options, 5, "the duplicate is removed")
+	assert.Equal(t, &ElapsedTimeOption{ElapsedTime: 100}, d.Options[4])
+
+	d.ReplaceOption(&PreferenceOption{PreferenceValue: 1})
+	assert.Equal(t, &PreferenceOption{PreferenceValue: 1}, d.Options[5], "appended when missing")
+
+	relay := relayMessage(0, d)
+	relay.ReplaceOption(&InterfaceIdOption{InterfaceId: []byte("eth1")})
+	assert.Equal(t, &InterfaceIdOption{InterfaceId: []byte("eth1")}, relay.Options[0])
+	assert.Len(t, relay.Options, 2)
+	assert.Equal(t, 1, relay.RemoveOptions(OptionCodeInterfaceId))
+	relay.AddOption(&InterfaceIdOption{InterfaceId: []byte("eth2")})
+	assert.Equal(t, OptionCodeInterfaceId, relay.Options[1].Code())
+}
@@ -0,0 +1,24 @@
+package dhcpv6
+
+import (
+	"golang.org/x/net/ipv6"
+	"net"
+)
+
+// JoinDhcpMulticast joins conn to the All_DHCP_Relay_Agents_and_Servers group
+// (ff02::1:2) on iface, so that messages sent to it by clients on that link
+// are received.
+func JoinDhcpMulticast(conn *net.UDPConn, iface *net.Interface) error {
+	return ipv6.NewPacketConn(conn).JoinGroup(iface, AllRelayAgentsAndServersAddr())
+}
+
+// LeaveDhcpMulticast reverses JoinDhcpMulticast.
+func LeaveDhcpMulticast(conn *net.UDPConn, iface *net.Interface) error {
+	return ipv6.NewPacketConn(conn).LeaveGroup(iface, AllRelayAgentsAndServersAddr())
+}
+
+// joinAllServers joins conn to the All_DHCP_Servers group (ff05::1:3) on
+// iface, through which relay agents may reach the servers of a site.
+func joinAllServers(conn *net.UDPConn, iface *net.Interface) error {
+	return ipv6.NewPacketConn(conn).JoinGroup(iface, AllServersAddr())
+}
@@ -0,0 +1,22 @@
+//go:build multicast
+
+package dhcpv6
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+func TestJoinDhcpMulticast(t *testing.T) {
+	iface := multicastInterface(t)
+	conn, err := net.ListenUDP("udp6", &net.UDPAddr{IP: net.IPv6unspecified})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	assert.NoError(t, JoinDhcpMulticast(conn, iface))
+	assert.NoError(t, LeaveDhcpMulticast(conn, iface))
+	assert.Error(t, LeaveDhcpMulticast(conn, iface), "the group was already left")
+}
@@ -0,0 +1,77 @@
+package dhcpv6
+
+import (
+	"strings"
+)
+
+// EncodeDomainName encodes name in the uncompressed DNS wire format (RFC 1035
+// section 3.1), as a sequence of length-prefixed labels terminated by the zero
+// length root label. A trailing dot on name is optional.
+//
+// Labels must be 1 to 63 octets long (ErrInvalidData), and the encoded name
+// may not exceed 255 octets (ErrWontFit).
+func EncodeDomainName(name string) ([]byte, error) {
+	name = strings.TrimSuffix(name, ".")
+	data := make([]byte, 0, len(name)+2)
+	if name != "" {
+		for _, label := range strings.Split(name, ".") {
+			if len(label) == 0 || len(label) > 63 {
+				return nil, ErrInvalidData
+			}
+			data = append(data, byte(len(label)))
+			data = append(data, label...)
+		}
+	}
+	data = append(data, 0)
+	if len(data) > 255 {
+		return nil, ErrWontFit
+	}
+	return data, nil
+}
+
+// encodedDomainNameLen returns the length of a valid name once encoded by
+// EncodeDomainName.
+func encodedDomainNameLen(name string) int {
+	name = strings.TrimSuffix(name, ".")
+	if name == "" {
+		return 1
+	}
+	return len(name) + 2
+}
+
+// DecodeDomainName decodes a single name in the uncompressed DNS wire format,
+// returning it without a trailing dot along with the number of bytes consumed,
+// so that a list of names can be decoded by advancing through data.
+//
+// Compression pointers are not permitted in DHCPv6 (RFC 3315 section 8), and
+// like labels over 63 octets or names over 255 octets, result in
+// ErrInvalidData.
+func DecodeDomainName(data []byte) (string, int, error) {
+	labels := make([]string, 0, 4)
+	pos := 0
+	for {
+		if pos >= len(data) {
+			return "", 0, ErrUnexpectedEOF
+		}
+		size := int(data[pos])
+		if size == 0 {
+			pos++
+			break
+		}
+		if size > 63 {
+			return "", 0, ErrInvalidData
+		}
+		if pos+1+size > len(data) {
+			return "", 0, ErrUnexpectedEOF
+		}
+		labels = append(labels, string(data[pos+1:pos+1+size]))
+		pos += 1 + size
+		if pos > 255 {
+			return "", 0, ErrInvalidData
+		}
+	}
+	if pos > 255 {
+		return "", 0, ErrInvalidData
+	}
+	return strings.Join(labels, "."), pos, nil
+}
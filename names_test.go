@@ -0,0 +1,68 @@
+package dhcpv6
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestEncodeDomainName(t *testing.T) {
+	data, err := EncodeDomainName("host.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x04, 'h', 'o', 's', 't', 0x07, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 0x03, 'c', 'o', 'm', 0x00}, data)
+
+	trailing, err := EncodeDomainName("host.example.com.")
+	assert.NoError(t, err)
+	assert.Equal(t, data, trailing)
+
+	data, err = EncodeDomainName("")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x00}, data)
+
+	_, err = EncodeDomainName("host..example.com")
+	assert.Equal(t, ErrInvalidData, err)
+	_, err = EncodeDomainName(strings.Repeat("a", 64) + ".com")
+	assert.Equal(t, ErrInvalidData, err)
+
+	//four labels of 63 octets encode to 4*64+1 = 257 octets
+	label := strings.Repeat("a", 63)
+	_, err = EncodeDomainName(strings.Join([]string{label, label, label, label}, "."))
+	assert.Equal(t, ErrWontFit, err)
+}
+
+func TestDecodeDomainName(t *testing.T) {
+	list := []byte{
+		0x07, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 0x03, 'c', 'o', 'm', 0x00,
+		0x07, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 0x03, 'n', 'e', 't', 0x00,
+	}
+	name, n, err := DecodeDomainName(list)
+	assert.NoError(t, err)
+	assert.Equal(t, "example.com", name)
+	assert.Equal(t, 13, n)
+	name, n, err = DecodeDomainName(list[n:])
+	assert.NoError(t, err)
+	assert.Equal(t, "example.net", name)
+	assert.Equal(t, 13, n)
+
+	//compression pointer to offset 0
+	_, _, err = DecodeDomainName([]byte{0x04, 'h', 'o', 's', 't', 0xc0, 0x00})
+	assert.Equal(t, ErrInvalidData, err)
+
+	_, _, err = DecodeDomainName([]byte{0x04, 'h', 'o', 's', 't'})
+	assert.Equal(t, ErrUnexpectedEOF, err)
+	_, _, err = DecodeDomainName([]byte{0x04, 'h', 'o'})
+	assert.Equal(t, ErrUnexpectedEOF, err)
+
+	//a name of 256 octets: four labels of 62 octets and one of 2
+	var long []byte
+	for i := 0; i < 4; i++ {
+		long = append(long, 62)
+		long = append(long, strings.Repeat("a", 62)...)
+	}
+	long = append(long, 2, 'a', 'a', 0)
+	assert.Len(t, long, 256)
+	_, _, err = DecodeDomainName(long)
+	assert.Equal(t, ErrInvalidData, err)
+	_, _, err = DecodeDomainName(append(long[:252], 0))
+	assert.NoError(t, err)
+}
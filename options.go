@@ -3,38 +3,53 @@ package dhcpv6
 import (
 	"encoding"
 	"encoding/binary"
+	"fmt"
 	"net"
+	"net/netip"
+	"strings"
+	"time"
 )
 
 type OptionCode uint16
 
 const (
 	//Options
-	OptionCodeClientId     OptionCode = 1
-	OptionCodeServerId     OptionCode = 2
-	OptionCodeIaNa         OptionCode = 3
-	OptionCodeIaTa         OptionCode = 4
-	OptionCodeIaAddr       OptionCode = 5
-	OptionCodeOro          OptionCode = 6
-	OptionCodePreference   OptionCode = 7
-	OptionCodeElapsedTime  OptionCode = 8
-	OptionCodeRelayMsg     OptionCode = 9
-	OptionCodeAuth         OptionCode = 11
-	OptionCodeUnicast      OptionCode = 12
-	OptionCodeStatusCode   OptionCode = 13
-	OptionCodeRapidCommit  OptionCode = 14
-	OptionCodeUserClass    OptionCode = 15
-	OptionCodeVendorClass  OptionCode = 16
-	OptionCodeVendorOpts   OptionCode = 17
-	OptionCodeInterfaceId  OptionCode = 18
-	OptionCodeReconfMsg    OptionCode = 19
-	OptionCodeReconfAccept OptionCode = 20
-	OptionCodeIaPd         OptionCode = 25
-	OptionCodeIaPrefix     OptionCode = 26
-	OptionCodeFQDN         OptionCode = 39
-	OptionCodeNextHop      OptionCode = 242
-	OptionCodeRtPrefix     OptionCode = 243
-	OptionCodeMTU          OptionCode = 244
+	OptionCodeClientId               OptionCode = 1
+	OptionCodeServerId               OptionCode = 2
+	OptionCodeIaNa                   OptionCode = 3
+	OptionCodeIaTa                   OptionCode = 4
+	OptionCodeIaAddr                 OptionCode = 5
+	OptionCodeOro                    OptionCode = 6
+	OptionCodePreference             OptionCode = 7
+	OptionCodeElapsedTime            OptionCode = 8
+	OptionCodeRelayMsg               OptionCode = 9
+	OptionCodeAuth                   OptionCode = 11
+	OptionCodeUnicast                OptionCode = 12
+	OptionCodeStatusCode             OptionCode = 13
+	OptionCodeRapidCommit            OptionCode = 14
+	OptionCodeUserClass              OptionCode = 15
+	OptionCodeVendorClass            OptionCode = 16
+	OptionCodeVendorOpts             OptionCode = 17
+	OptionCodeInterfaceId            OptionCode = 18
+	OptionCodeReconfMsg              OptionCode = 19
+	OptionCodeReconfAccept           OptionCode = 20
+	OptionCodeDnsServers             OptionCode = 23
+	OptionCodeDomainList             OptionCode = 24
+	OptionCodeIaPd                   OptionCode = 25
+	OptionCodeIaPrefix               OptionCode = 26
+	OptionCodeInformationRefreshTime OptionCode = 32
+	OptionCodeRemoteId               OptionCode = 37
+	OptionCodeSubscriberId           OptionCode = 38
+	OptionCodeFQDN                   OptionCode = 39
+	OptionCodeNtpServer              OptionCode = 56
+	OptionCodeS46Rule                OptionCode = 89
+	OptionCodeS46Br                  OptionCode = 90
+	OptionCodeS46ContMapE            OptionCode = 94
+	OptionCodeS46ContMapT            OptionCode = 95
+	OptionCodeS46ContLw              OptionCode = 96
+	OptionCodeNextHop                OptionCode = 242
+	OptionCodeRtPrefix               OptionCode = 243
+	OptionCodeMTU                    OptionCode = 244
 )
 
 // DHCPv6 options are scoped by using encapsulation.  Some options apply
@@ -46,66 +61,157 @@ type Option interface {
 	Code() OptionCode
 }
 
+// optionType describes a decodable option type.
+type optionType struct {
+	name string
+	new  func() Option
+}
+
+// optionTypes maps each option code to its name and a constructor for the
+// structure used to decode it.
+var optionTypes = map[OptionCode]optionType{
+	OptionCodeClientId:               {"CLIENTID", func() Option { return new(ClientIdOption) }},
+	OptionCodeServerId:               {"SERVERID", func() Option { return new(ServerIdOption) }},
+	OptionCodeIaNa:                   {"IA_NA", func() Option { return new(IaNaOption) }},
+	OptionCodeIaTa:                   {"IA_TA", func() Option { return new(IaTaOption) }},
+	OptionCodeIaAddr:                 {"IAADDR", func() Option { return new(IaAddrOption) }},
+	OptionCodeIaPd:                   {"IA_PD", func() Option { return new(IaPdOption) }},
+	OptionCodeIaPrefix:               {"IAPREFIX", func() Option { return new(IaPrefixOption) }},
+	OptionCodeOro:                    {"ORO", func() Option { return new(OroOption) }},
+	OptionCodePreference:             {"PREFERENCE", func() Option { return new(PreferenceOption) }},
+	OptionCodeElapsedTime:            {"ELAPSED_TIME", func() Option { return new(ElapsedTimeOption) }},
+	OptionCodeRelayMsg:               {"RELAY_MSG", func() Option { return new(RelayMsgOption) }},
+	OptionCodeAuth:                   {"AUTH", func() Option { return new(AuthOption) }},
+	OptionCodeUnicast:                {"UNICAST", func() Option { return new(UnicastOption) }},
+	OptionCodeStatusCode:             {"STATUS_CODE", func() Option { return new(StatusCodeOption) }},
+	OptionCodeRapidCommit:            {"RAPID_COMMIT", func() Option { return new(RapidCommitOption) }},
+	OptionCodeUserClass:              {"USER_CLASS", func() Option { return new(UserClassOption) }},
+	OptionCodeVendorClass:            {"VENDOR_CLASS", func() Option { return new(VendorClassOption) }},
+	OptionCodeVendorOpts:             {"VENDOR_OPTS", func() Option { return new(VendorOptsOption) }},
+	OptionCodeInterfaceId:            {"INTERFACE_ID", func() Option { return new(InterfaceIdOption) }},
+	OptionCodeReconfMsg:              {"RECONF_MSG", func() Option { return new(ReconfMsgOption) }},
+	OptionCodeReconfAccept:           {"RECONF_ACCEPT", func() Option { return new(ReconfAcceptOption) }},
+	OptionCodeDnsServers:             {"DNS_SERVERS", func() Option { return new(DnsServersOption) }},
+	OptionCodeDomainList:             {"DOMAIN_LIST", func() Option { return new(DomainSearchListOption) }},
+	OptionCodeInformationRefreshTime: {"INFORMATION_REFRESH_TIME", func() Option { return new(InformationRefreshTimeOption) }},
+	OptionCodeRemoteId:               {"REMOTE_ID", func() Option { return new(RemoteIdOption) }},
+	OptionCodeSubscriberId:           {"SUBSCRIBER_ID", func() Option { return new(SubscriberIdOption) }},
+	OptionCodeFQDN:                   {"CLIENT_FQDN", func() Option { return new(FQDNOption) }},
+	OptionCodeNtpServer:              {"NTP_SERVER", func() Option { return new(NtpServerOption) }},
+	OptionCodeS46Rule:                {"S46_RULE", func() Option { return new(S46RuleOption) }},
+	OptionCodeS46Br:                  {"S46_BR", func() Option { return new(S46BrOption) }},
+	OptionCodeS46ContMapE:            {"S46_CONT_MAPE", func() Option { return new(S46ContMapEOption) }},
+	OptionCodeS46ContMapT:            {"S46_CONT_MAPT", func() Option { return new(S46ContMapTOption) }},
+	OptionCodeS46ContLw:              {"S46_CONT_LW", func() Option { return new(S46ContLwOption) }},
+	OptionCodeNextHop:                {"NEXT_HOP", func() Option { return new(NextHopOption) }},
+	OptionCodeRtPrefix:               {"RTPREFIX", func() Option { return new(RtPrefixOption) }},
+	OptionCodeMTU:                    {"MTU", func() Option { return new(MTUOption) }},
+}
+
+// optionRange maps an inclusive range of option codes to a constructor that
+// is given the actual code being decoded.
+type optionRange struct {
+	lo, hi OptionCode
+	new    func(OptionCode) Option
+}
+
+var optionRanges []optionRange
+
+// RegisterOptionRange will decode every option with a code between lo and hi
+// (inclusive) using the Option returned by factory, which is given the actual
+// code. This allows, for example, a single type to handle a private-use range.
+//
+// Codes with a type of their own are never affected, and when ranges overlap the
+// one registered last wins. It is not safe to register ranges while options are
+// being decoded.
+func RegisterOptionRange(lo, hi OptionCode, factory func(OptionCode) Option) {
+	optionRanges = append(optionRanges, optionRange{lo, hi, factory})
+}
+
+// RegisteredOptionCodes returns the name of every option type that will be
+// decoded to its own structure (rather than an UnknownOption), by code.
+func RegisteredOptionCodes() map[OptionCode]string {
+	codes := make(map[OptionCode]string, len(optionTypes))
+	for code, t := range optionTypes {
+		codes[code] = t.name
+	}
+	return codes
+}
+
+// NewOptionByCode will construct a zero-value option of the type registered
+// for code, falling back to any range registered with RegisterOptionRange.
+// Undefined codes result in an UnknownOption.
+func NewOptionByCode(code OptionCode) Option {
+	if t, ok := optionTypes[code]; ok {
+		return t.new()
+	}
+	for i := len(optionRanges) - 1; i >= 0; i-- {
+		if r := optionRanges[i]; code >= r.lo && code <= r.hi {
+			return r.new(code)
+		}
+	}
+	return &UnknownOption{OptionCode: code}
+}
+
 // UnmarshalBinaryOption will take the raw wire-format data and construct
 // the correct structure underneath, returning the Option interface.
 //
 // If the option type is not defined the option will be decoded as an UnknownOption
 // allowing raw access to the option code and data.
 func UnmarshalBinaryOption(data []byte) (option Option, err error) {
-	switch OptionCode(binary.BigEndian.Uint16(data)) {
-	case OptionCodeClientId:
-		option = new(ClientIdOption)
-	case OptionCodeServerId:
-		option = new(ServerIdOption)
-	case OptionCodeIaNa:
-		option = new(IaNaOption)
-	case OptionCodeIaTa:
-		option = new(IaTaOption)
-	case OptionCodeIaAddr:
-		option = new(IaAddrOption)
-	case OptionCodeOro:
-		option = new(OroOption)
-	case OptionCodePreference:
-		option = new(PreferenceOption)
-	case OptionCodeElapsedTime:
-		option = new(ElapsedTimeOption)
-	case OptionCodeRelayMsg:
-		option = new(RelayMsgOption)
-	case OptionCodeAuth:
-		option = new(AuthOption)
-	case OptionCodeUnicast:
-		option = new(UnicastOption)
-	case OptionCodeStatusCode:
-		option = new(StatusCodeOption)
-	case OptionCodeRapidCommit:
-		option = new(RapidCommitOption)
-	case OptionCodeUserClass:
-		option = new(UserClassOption)
-	case OptionCodeVendorClass:
-		option = new(VendorClassOption)
-	case OptionCodeVendorOpts:
-		option = new(VendorOptsOption)
-	case OptionCodeInterfaceId:
-		option = new(InterfaceIdOption)
-	case OptionCodeReconfMsg:
-		option = new(ReconfMsgOption)
-	case OptionCodeReconfAccept:
-		option = new(ReconfAcceptOption)
-	case OptionCodeFQDN:
-		option = new(FQDNOption)
-	case OptionCodeNextHop:
-		option = new(NextHopOption)
-	case OptionCodeRtPrefix:
-		option = new(RtPrefixOption)
-	case OptionCodeMTU:
-		option = new(MTUOption)
-	default:
-		option = new(UnknownOption)
+	if len(data) < 4 {
+		return nil, ErrUnexpectedEOF
 	}
+	option = NewOptionByCode(OptionCode(binary.BigEndian.Uint16(data)))
 	err = option.UnmarshalBinary(data)
 	return
 }
 
+// subOptions returns a pointer to the list of options encapsulated by o, or
+// nil if o does not carry any.
+func subOptions(o Option) *[]Option {
+	switch v := o.(type) {
+	case *IaNaOption:
+		return &v.IaNaOptions
+	case *IaTaOption:
+		return &v.IaTaOptions
+	case *IaAddrOption:
+		return &v.IAddrOptions
+	case *IaPdOption:
+		return &v.IaPdOptions
+	case *IaPrefixOption:
+		return &v.IaPrefixOptions
+	case *NextHopOption:
+		return &v.NextHopOptions
+	case *RelayMsgOption:
+		switch msg := v.DhcpRelayMessage.(type) {
+		case *DhcpMessage:
+			return &msg.Options
+		case *DhcpRelayMessage:
+			return &msg.Options
+		}
+	case *S46RuleOption:
+		return &v.S46RuleOptions
+	case *S46ContMapEOption:
+		return &v.S46Options
+	case *S46ContMapTOption:
+		return &v.S46Options
+	case *S46ContLwOption:
+		return &v.S46Options
+	}
+	return nil
+}
+
+// walkOptions calls fn for each option, and each option nested within it.
+func walkOptions(options []Option, fn func(Option)) {
+	for _, o := range options {
+		fn(o)
+		if sub := subOptions(o); sub != nil {
+			walkOptions(*sub, fn)
+		}
+	}
+}
+
 // UnknownOption is not a defined type, it is just a placeholder for undefined
 // option types.
 type UnknownOption struct {
@@ -117,14 +223,17 @@ func (o *UnknownOption) Code() OptionCode {
 	return o.OptionCode
 }
 func (o *UnknownOption) MarshalBinary() ([]byte, error) {
+	return o.AppendBinary(make([]byte, 0, 4+len(o.OptionData)))
+}
+
+// AppendBinary appends the wire-format of the option to data.
+func (o *UnknownOption) AppendBinary(data []byte) ([]byte, error) {
 	if len(o.OptionData) > 65535 {
 		return nil, ErrWontFit
 	}
-	data := make([]byte, 4+len(o.OptionData))
-	binary.BigEndian.PutUint16(data, uint16(o.OptionCode))
-	binary.BigEndian.PutUint16(data[2:], uint16(len(o.OptionData)))
-	copy(data[4:], o.OptionData)
-	return data, nil
+	data = binary.BigEndian.AppendUint16(data, uint16(o.OptionCode))
+	data = binary.BigEndian.AppendUint16(data, uint16(len(o.OptionData)))
+	return append(data, o.OptionData...), nil
 }
 func (o *UnknownOption) UnmarshalBinary(data []byte) error {
 	if len(data) < 4 {
@@ -135,7 +244,7 @@ func (o *UnknownOption) UnmarshalBinary(data []byte) error {
 	if len(data) < int(olen)+4 {
 		return ErrUnexpectedEOF
 	}
-	o.OptionData = data[4 : olen+4]
+	o.OptionData = cloneBytes(data[4 : olen+4])
 	return nil
 }
 
@@ -146,19 +255,33 @@ type ClientIdOption struct {
 	Duid Duid
 }
 
+// NewClientId will create a Client Identifier option carrying d.
+func NewClientId(d Duid) *ClientIdOption {
+	return &ClientIdOption{Duid: d}
+}
+
+// HardwareAddr returns the link-layer address of a DUID-LLT or DUID-LL,
+// reporting false for any other type of DUID.
+func (o *ClientIdOption) HardwareAddr() (net.HardwareAddr, bool) {
+	return duidHardwareAddr(o.Duid)
+}
+
 func (o *ClientIdOption) Code() OptionCode {
 	return OptionCodeClientId
 }
 func (o *ClientIdOption) MarshalBinary() ([]byte, error) {
-	data := make([]byte, 4, 134) //maximum length of a DUID is 128+2
-	binary.BigEndian.PutUint16(data, uint16(OptionCodeClientId))
+	return o.AppendBinary(make([]byte, 0, 134)) //maximum length of a DUID is 128+2
+}
+
+// AppendBinary appends the wire-format of the option to data.
+func (o *ClientIdOption) AppendBinary(data []byte) ([]byte, error) {
 	duidData, err := o.Duid.MarshalBinary()
 	if err != nil {
 		return nil, err
 	}
-	binary.BigEndian.PutUint16(data[2:], uint16(len(duidData)))
-	data = append(data, duidData...)
-	return data, nil
+	data = binary.BigEndian.AppendUint16(data, uint16(OptionCodeClientId))
+	data = binary.BigEndian.AppendUint16(data, uint16(len(duidData)))
+	return append(data, duidData...), nil
 }
 func (o *ClientIdOption) UnmarshalBinary(data []byte) error {
 	if len(data) < 4 {
@@ -188,19 +311,27 @@ type ServerIdOption struct {
 	Duid Duid
 }
 
+// NewServerId will create a Server Identifier option carrying d.
+func NewServerId(d Duid) *ServerIdOption {
+	return &ServerIdOption{Duid: d}
+}
+
 func (o *ServerIdOption) Code() OptionCode {
 	return OptionCodeServerId
 }
 func (o *ServerIdOption) MarshalBinary() ([]byte, error) {
-	data := make([]byte, 4, 134) //maximum length of a DUID is 128+2
-	binary.BigEndian.PutUint16(data, uint16(OptionCodeServerId))
+	return o.AppendBinary(make([]byte, 0, 134)) //maximum length of a DUID is 128+2
+}
+
+// AppendBinary appends the wire-format of the option to data.
+func (o *ServerIdOption) AppendBinary(data []byte) ([]byte, error) {
 	duidData, err := o.Duid.MarshalBinary()
 	if err != nil {
 		return nil, err
 	}
-	binary.BigEndian.PutUint16(data[2:], uint16(len(duidData)))
-	data = append(data, duidData...)
-	return data, nil
+	data = binary.BigEndian.AppendUint16(data, uint16(OptionCodeServerId))
+	data = binary.BigEndian.AppendUint16(data, uint16(len(duidData)))
+	return append(data, duidData...), nil
 }
 func (o *ServerIdOption) UnmarshalBinary(data []byte) error {
 	if len(data) < 4 {
@@ -234,6 +365,11 @@ func (o *IaNaOption) Code() OptionCode {
 	return OptionCodeIaNa
 }
 func (o *IaNaOption) MarshalBinary() ([]byte, error) {
+	if o.T2 != 0 && o.T1 > o.T2 {
+		//RFC 3315 section 22.4, T1 may not exceed T2, except that a T1 or T2
+		//of 0 leaves that time to the client
+		return nil, ErrInvalidData
+	}
 	var data []byte
 	if len(o.IaNaOptions) == 0 {
 		data = make([]byte, 16)
@@ -249,7 +385,7 @@ func (o *IaNaOption) MarshalBinary() ([]byte, error) {
 		if err != nil {
 			return nil, err
 		}
-		if len(data)+len(optionData) > cap(data) {
+		if len(data)+len(optionData) > 65539 {
 			return nil, ErrWontFit
 		}
 		data = append(data, optionData...)
@@ -258,13 +394,16 @@ func (o *IaNaOption) MarshalBinary() ([]byte, error) {
 	return data, nil
 }
 func (o *IaNaOption) UnmarshalBinary(data []byte) error {
-	if len(data) < 16 {
+	if len(data) < 4 {
 		return ErrUnexpectedEOF
 	}
 	if binary.BigEndian.Uint16(data) != uint16(OptionCodeIaNa) {
 		return ErrInvalidType
 	}
 	olen := binary.BigEndian.Uint16(data[2:])
+	if olen < 12 {
+		return ErrInvalidData
+	}
 	if len(data) < int(olen)+4 {
 		return ErrUnexpectedEOF
 	}
@@ -285,6 +424,12 @@ func (o *IaNaOption) UnmarshalBinary(data []byte) error {
 			return ErrUnexpectedEOF
 		}
 		nextSize := binary.BigEndian.Uint16(optionData[2:])
+		if len(optionData) < int(nextSize)+4 {
+			return ErrUnexpectedEOF
+		}
+		if len(o.IaNaOptions) == DefaultMaxOptions {
+			return ErrTooManyOptions
+		}
 		option, err := UnmarshalBinaryOption(optionData[:nextSize+4])
 		if err != nil {
 			return err
@@ -295,6 +440,29 @@ func (o *IaNaOption) UnmarshalBinary(data []byte) error {
 	return nil
 }
 
+// EffectiveTimers returns the T1 and T2 values a client should use. When the
+// server left them at zero, they are derived from the preferred lifetime as
+// recommended by RFC 3315 section 22.4 (0.5 and 0.8 times preferred).
+func (o *IaNaOption) EffectiveTimers(preferred uint32) (t1, t2 uint32) {
+	t1, t2 = o.T1, o.T2
+	if t1 == 0 {
+		t1 = deriveTimer(preferred, 5)
+	}
+	if t2 == 0 {
+		t2 = deriveTimer(preferred, 8)
+	}
+	return t1, t2
+}
+
+// deriveTimer returns tenths/10 of the preferred lifetime, keeping an
+// infinite lifetime as-is.
+func deriveTimer(preferred uint32, tenths uint64) uint32 {
+	if preferred == InfiniteLifetime {
+		return InfiniteLifetime
+	}
+	return uint32(uint64(preferred) * tenths / 10)
+}
+
 // Identity Association for Temporary Addresses Option
 type IaTaOption struct {
 	IAID        [4]byte
@@ -318,7 +486,7 @@ func (o *IaTaOption) MarshalBinary() ([]byte, error) {
 		if err != nil {
 			return nil, err
 		}
-		if len(data)+len(optionData) > cap(data) {
+		if len(data)+len(optionData) > 65539 {
 			return nil, ErrWontFit
 		}
 		data = append(data, optionData...)
@@ -327,13 +495,16 @@ func (o *IaTaOption) MarshalBinary() ([]byte, error) {
 	return data, nil
 }
 func (o *IaTaOption) UnmarshalBinary(data []byte) error {
-	if len(data) < 8 {
+	if len(data) < 4 {
 		return ErrUnexpectedEOF
 	}
 	if binary.BigEndian.Uint16(data) != uint16(OptionCodeIaTa) {
 		return ErrInvalidType
 	}
 	olen := binary.BigEndian.Uint16(data[2:])
+	if olen < 4 {
+		return ErrInvalidData
+	}
 	if len(data) < int(olen)+4 {
 		return ErrUnexpectedEOF
 	}
@@ -352,6 +523,12 @@ func (o *IaTaOption) UnmarshalBinary(data []byte) error {
 			return ErrUnexpectedEOF
 		}
 		nextSize := binary.BigEndian.Uint16(optionData[2:])
+		if len(optionData) < int(nextSize)+4 {
+			return ErrUnexpectedEOF
+		}
+		if len(o.IaTaOptions) == DefaultMaxOptions {
+			return ErrTooManyOptions
+		}
 		option, err := UnmarshalBinaryOption(optionData[:nextSize+4])
 		if err != nil {
 			return err
@@ -378,11 +555,11 @@ func (o *IaAddrOption) MarshalBinary() ([]byte, error) {
 	if len(o.IAddrOptions) == 0 {
 		data = make([]byte, 28)
 	} else {
-		data = make([]byte, 28, 63359) //65535+4
+		data = make([]byte, 28, 65539) //65535+4
 	}
 	binary.BigEndian.PutUint16(data, uint16(OptionCodeIaAddr))
-	if len(o.Ipv6Address) != net.IPv6len {
-		return nil, ErrInvalidIpv6Address
+	if err := checkIpv6Address(o.Ipv6Address); err != nil {
+		return nil, err
 	}
 	copy(data[4:], o.Ipv6Address)
 	binary.BigEndian.PutUint32(data[20:], o.PreferredLifetime)
@@ -392,7 +569,7 @@ func (o *IaAddrOption) MarshalBinary() ([]byte, error) {
 		if err != nil {
 			return nil, err
 		}
-		if len(data)+len(optionData) > cap(data) {
+		if len(data)+len(optionData) > 65539 {
 			return nil, ErrWontFit
 		}
 		data = append(data, optionData...)
@@ -401,20 +578,23 @@ func (o *IaAddrOption) MarshalBinary() ([]byte, error) {
 	return data, nil
 }
 func (o *IaAddrOption) UnmarshalBinary(data []byte) error {
-	if len(data) < 28 {
+	if len(data) < 4 {
 		return ErrUnexpectedEOF
 	}
 	if binary.BigEndian.Uint16(data) != uint16(OptionCodeIaAddr) {
 		return ErrInvalidType
 	}
 	olen := binary.BigEndian.Uint16(data[2:])
+	if olen < 24 {
+		return ErrInvalidData
+	}
 	if len(data) < int(olen)+4 {
 		return ErrUnexpectedEOF
 	}
-	o.Ipv6Address = net.IP(data[4:20])
+	o.Ipv6Address = net.IP(cloneBytes(data[4:20]))
 	o.PreferredLifetime = binary.BigEndian.Uint32(data[20:])
 	o.ValidLifetime = binary.BigEndian.Uint32(data[24:])
-	if len(data) == 28 {
+	if olen+4 == 28 {
 		o.IAddrOptions = make([]Option, 0)
 	} else {
 		//TODO: better way to guess capacity?
@@ -426,6 +606,12 @@ func (o *IaAddrOption) UnmarshalBinary(data []byte) error {
 			return ErrUnexpectedEOF
 		}
 		nextSize := binary.BigEndian.Uint16(optionData[2:])
+		if len(optionData) < int(nextSize)+4 {
+			return ErrUnexpectedEOF
+		}
+		if len(o.IAddrOptions) == DefaultMaxOptions {
+			return ErrTooManyOptions
+		}
 		option, err := UnmarshalBinaryOption(optionData[:nextSize+4])
 		if err != nil {
 			return err
@@ -436,6 +622,182 @@ func (o *IaAddrOption) UnmarshalBinary(data []byte) error {
 	return nil
 }
 
+// Addr returns the address as a netip.Addr, or the zero Addr if it is not set.
+func (o *IaAddrOption) Addr() netip.Addr {
+	addr, _ := netip.AddrFromSlice(o.Ipv6Address)
+	return addr
+}
+
+// SetAddr sets the address from a netip.Addr, always in its 16-byte form.
+func (o *IaAddrOption) SetAddr(addr netip.Addr) {
+	ip := addr.As16()
+	o.Ipv6Address = net.IP(ip[:])
+}
+
+// SetInfiniteLifetimes marks the address as never expiring.
+func (o *IaAddrOption) SetInfiniteLifetimes() {
+	o.PreferredLifetime = InfiniteLifetime
+	o.ValidLifetime = InfiniteLifetime
+}
+
+// Identity Association for Prefix Delegation Option
+//
+// https://tools.ietf.org/html/rfc3633#section-9
+type IaPdOption struct {
+	IAID        [4]byte
+	T1          uint32
+	T2          uint32
+	IaPdOptions []Option
+}
+
+func (o *IaPdOption) Code() OptionCode {
+	return OptionCodeIaPd
+}
+func (o *IaPdOption) MarshalBinary() ([]byte, error) {
+	if o.T2 != 0 && o.T1 > o.T2 {
+		//RFC 3315 section 22.4, T1 may not exceed T2, except that a T1 or T2
+		//of 0 leaves that time to the client
+		return nil, ErrInvalidData
+	}
+	var data []byte
+	if len(o.IaPdOptions) == 0 {
+		data = make([]byte, 16)
+	} else {
+		data = make([]byte, 16, 65539) //65535+4
+	}
+	binary.BigEndian.PutUint16(data, uint16(OptionCodeIaPd))
+	copy(data[4:], o.IAID[:])
+	binary.BigEndian.PutUint32(data[8:], o.T1)
+	binary.BigEndian.PutUint32(data[12:], o.T2)
+	for i := range o.IaPdOptions {
+		optionData, err := o.IaPdOptions[i].MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		if len(data)+len(optionData) > 65539 {
+			return nil, ErrWontFit
+		}
+		data = append(data, optionData...)
+	}
+	binary.BigEndian.PutUint16(data[2:], uint16(len(data)-4))
+	return data, nil
+}
+func (o *IaPdOption) UnmarshalBinary(data []byte) error {
+	if len(data) < 4 {
+		return ErrUnexpectedEOF
+	}
+	if binary.BigEndian.Uint16(data) != uint16(OptionCodeIaPd) {
+		return ErrInvalidType
+	}
+	olen := binary.BigEndian.Uint16(data[2:])
+	if olen < 12 {
+		return ErrInvalidData
+	}
+	if len(data) < int(olen)+4 {
+		return ErrUnexpectedEOF
+	}
+
+	copy(o.IAID[:], data[4:8])
+	o.T1 = binary.BigEndian.Uint32(data[8:])
+	o.T2 = binary.BigEndian.Uint32(data[12:])
+	options, err := unmarshalSubOptions(data[16 : olen+4])
+	if err != nil {
+		return err
+	}
+	o.IaPdOptions = options
+	return nil
+}
+
+// IA Prefix Option
+//
+// https://tools.ietf.org/html/rfc3633#section-10
+type IaPrefixOption struct {
+	PreferredLifetime uint32
+	ValidLifetime     uint32
+	PrefixLength      uint8
+	Ipv6Prefix        net.IP
+	IaPrefixOptions   []Option
+}
+
+func (o *IaPrefixOption) Code() OptionCode {
+	return OptionCodeIaPrefix
+}
+func (o *IaPrefixOption) MarshalBinary() ([]byte, error) {
+	if err := checkIpv6Address(o.Ipv6Prefix); err != nil {
+		return nil, err
+	}
+	if o.PrefixLength > 128 {
+		return nil, ErrInvalidData
+	}
+	var data []byte
+	if len(o.IaPrefixOptions) == 0 {
+		data = make([]byte, 29)
+	} else {
+		data = make([]byte, 29, 65539) //65535+4
+	}
+	binary.BigEndian.PutUint16(data, uint16(OptionCodeIaPrefix))
+	binary.BigEndian.PutUint32(data[4:], o.PreferredLifetime)
+	binary.BigEndian.PutUint32(data[8:], o.ValidLifetime)
+	data[12] = o.PrefixLength
+	copy(data[13:], o.Ipv6Prefix)
+	for i := range o.IaPrefixOptions {
+		optionData, err := o.IaPrefixOptions[i].MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		if len(data)+len(optionData) > 65539 {
+			return nil, ErrWontFit
+		}
+		data = append(data, optionData...)
+	}
+	binary.BigEndian.PutUint16(data[2:], uint16(len(data)-4))
+	return data, nil
+}
+func (o *IaPrefixOption) UnmarshalBinary(data []byte) error {
+	if len(data) < 4 {
+		return ErrUnexpectedEOF
+	}
+	if binary.BigEndian.Uint16(data) != uint16(OptionCodeIaPrefix) {
+		return ErrInvalidType
+	}
+	olen := binary.BigEndian.Uint16(data[2:])
+	if olen < 25 {
+		return ErrInvalidData
+	}
+	if len(data) < int(olen)+4 {
+		return ErrUnexpectedEOF
+	}
+	o.PreferredLifetime = binary.BigEndian.Uint32(data[4:])
+	o.ValidLifetime = binary.BigEndian.Uint32(data[8:])
+	o.PrefixLength = data[12]
+	if o.PrefixLength > 128 {
+		return ErrInvalidData
+	}
+	o.Ipv6Prefix = net.IP(cloneBytes(data[13:29]))
+	options, err := unmarshalSubOptions(data[29 : olen+4])
+	if err != nil {
+		return err
+	}
+	o.IaPrefixOptions = options
+	return nil
+}
+
+// Prefix returns the delegated prefix as a netip.Prefix, or the zero Prefix if
+// it is not set.
+func (o *IaPrefixOption) Prefix() netip.Prefix {
+	addr, ok := netip.AddrFromSlice(o.Ipv6Prefix)
+	if !ok {
+		return netip.Prefix{}
+	}
+	return netip.PrefixFrom(addr, int(o.PrefixLength))
+}
+
+// SetInfiniteLifetimes marks the prefix as never expiring.
+func (o *IaPrefixOption) SetInfiniteLifetimes() {
+	o.PreferredLifetime = InfiniteLifetime
+	o.ValidLifetime = InfiniteLifetime
+}
+
 // Option Request Option
 type OroOption struct {
 	RequestedOptionCodes []uint16
@@ -445,14 +807,18 @@ func (o *OroOption) Code() OptionCode {
 	return OptionCodeOro
 }
 func (o *OroOption) MarshalBinary() ([]byte, error) {
+	return o.AppendBinary(make([]byte, 0, 4+len(o.RequestedOptionCodes)*2))
+}
+
+// AppendBinary appends the wire-format of the option to data.
+func (o *OroOption) AppendBinary(data []byte) ([]byte, error) {
 	if len(o.RequestedOptionCodes) > 32767 {
 		return nil, ErrWontFit
 	}
-	data := make([]byte, 4+len(o.RequestedOptionCodes)*2)
-	binary.BigEndian.PutUint16(data, uint16(OptionCodeOro))
-	binary.BigEndian.PutUint16(data[2:], uint16(len(o.RequestedOptionCodes)*2))
-	for i := range o.RequestedOptionCodes {
-		binary.BigEndian.PutUint16(data[4+i*2:], o.RequestedOptionCodes[i])
+	data = binary.BigEndian.AppendUint16(data, uint16(OptionCodeOro))
+	data = binary.BigEndian.AppendUint16(data, uint16(len(o.RequestedOptionCodes)*2))
+	for _, code := range o.RequestedOptionCodes {
+		data = binary.BigEndian.AppendUint16(data, code)
 	}
 	return data, nil
 }
@@ -464,6 +830,9 @@ func (o *OroOption) UnmarshalBinary(data []byte) error {
 		return ErrInvalidType
 	}
 	olen := binary.BigEndian.Uint16(data[2:])
+	if olen%2 != 0 {
+		return ErrInvalidData
+	}
 	if len(data) < int(olen)+4 {
 		return ErrUnexpectedEOF
 	}
@@ -483,11 +852,14 @@ func (o *PreferenceOption) Code() OptionCode {
 	return OptionCodePreference
 }
 func (o *PreferenceOption) MarshalBinary() ([]byte, error) {
-	data := make([]byte, 5)
-	binary.BigEndian.PutUint16(data, uint16(OptionCodePreference))
-	binary.BigEndian.PutUint16(data[2:], 1)
-	data[4] = o.PreferenceValue
-	return data, nil
+	return o.AppendBinary(make([]byte, 0, 5))
+}
+
+// AppendBinary appends the wire-format of the option to data.
+func (o *PreferenceOption) AppendBinary(data []byte) ([]byte, error) {
+	data = binary.BigEndian.AppendUint16(data, uint16(OptionCodePreference))
+	data = binary.BigEndian.AppendUint16(data, 1)
+	return append(data, o.PreferenceValue), nil
 }
 func (o *PreferenceOption) UnmarshalBinary(data []byte) error {
 	if len(data) < 5 {
@@ -508,15 +880,31 @@ type ElapsedTimeOption struct {
 	ElapsedTime uint16
 }
 
+// ElapsedTimeSince will create an ElapsedTimeOption with the time elapsed since
+// start (see Now), in hundredths of a second. Values too large to be
+// represented are reported as 0xffff.
+func ElapsedTimeSince(start time.Time) *ElapsedTimeOption {
+	elapsed := Now().Sub(start) / (10 * time.Millisecond)
+	if elapsed < 0 {
+		elapsed = 0
+	} else if elapsed > 0xffff {
+		elapsed = 0xffff
+	}
+	return &ElapsedTimeOption{ElapsedTime: uint16(elapsed)}
+}
+
 func (o *ElapsedTimeOption) Code() OptionCode {
 	return OptionCodeElapsedTime
 }
 func (o *ElapsedTimeOption) MarshalBinary() ([]byte, error) {
-	data := make([]byte, 6)
-	binary.BigEndian.PutUint16(data, uint16(OptionCodeElapsedTime))
-	binary.BigEndian.PutUint16(data[2:], 2)
-	binary.BigEndian.PutUint16(data[4:], o.ElapsedTime)
-	return data, nil
+	return o.AppendBinary(make([]byte, 0, 6))
+}
+
+// AppendBinary appends the wire-format of the option to data.
+func (o *ElapsedTimeOption) AppendBinary(data []byte) ([]byte, error) {
+	data = binary.BigEndian.AppendUint16(data, uint16(OptionCodeElapsedTime))
+	data = binary.BigEndian.AppendUint16(data, 2)
+	return binary.BigEndian.AppendUint16(data, o.ElapsedTime), nil
 }
 func (o *ElapsedTimeOption) UnmarshalBinary(data []byte) error {
 	if len(data) < 6 {
@@ -533,17 +921,35 @@ func (o *ElapsedTimeOption) UnmarshalBinary(data []byte) error {
 }
 
 // Relay Message Option
+//
+// The relayed message is a client/server message (*DhcpMessage) at the bottom
+// of a relay chain, or another relay message (*DhcpRelayMessage) when relayed
+// through more than one relay agent.
 type RelayMsgOption struct {
-	DhcpRelayMessage DhcpMessage
+	DhcpRelayMessage Message
+
+	// RawInner, when set, is the already-encoded relayed message. It takes
+	// precedence over DhcpRelayMessage, being written as-is by MarshalBinary
+	// and decoded when the relayed message is read, allowing a relay agent to
+	// forward a message without decoding it. It is never set by
+	// UnmarshalBinary.
+	RawInner []byte
 }
 
 func (o *RelayMsgOption) Code() OptionCode {
 	return OptionCodeRelayMsg
 }
 func (o *RelayMsgOption) MarshalBinary() ([]byte, error) {
-	relayData, err := o.DhcpRelayMessage.MarshalBinary()
-	if err != nil {
-		return nil, err
+	relayData := o.RawInner
+	if relayData == nil {
+		if o.DhcpRelayMessage == nil {
+			return nil, ErrInvalidData
+		}
+		var err error
+		relayData, err = o.DhcpRelayMessage.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
 	}
 	if len(relayData) > 65535 {
 		return nil, ErrWontFit
@@ -565,13 +971,52 @@ func (o *RelayMsgOption) UnmarshalBinary(data []byte) error {
 	if len(data) < int(olen)+4 {
 		return ErrUnexpectedEOF
 	}
-	err := o.DhcpRelayMessage.UnmarshalBinary(data[4 : olen+4])
+	msg, err := UnmarshalBinaryMessage(data[4 : olen+4])
 	if err != nil {
 		return err
 	}
+	o.DhcpRelayMessage = msg
 	return nil
 }
 
+// message returns the relayed message, decoding RawInner if it is set, so
+// that the message read is always the one MarshalBinary would write.
+func (o *RelayMsgOption) message() (Message, error) {
+	if o.RawInner == nil {
+		return o.DhcpRelayMessage, nil
+	}
+	return UnmarshalBinaryMessage(o.RawInner)
+}
+
+// ClientMessage returns the relayed message when it is a client/server
+// message, that is when the option is at the bottom of a relay chain.
+//
+// A RawInner that can not be decoded reads as no message at all; use
+// DhcpRelayMessage.InnerMessage and UnmarshalBinaryMessage to tell the two
+// apart.
+func (o *RelayMsgOption) ClientMessage() (*DhcpMessage, bool) {
+	msg, err := o.message()
+	if err != nil {
+		return nil, false
+	}
+	m, ok := msg.(*DhcpMessage)
+	return m, ok
+}
+
+// RelayMessage returns the relayed message when it is itself a relay
+// message, as added by each further relay agent along the path.
+//
+// As with ClientMessage, a RawInner that can not be decoded reads as no
+// message at all.
+func (o *RelayMsgOption) RelayMessage() (*DhcpRelayMessage, bool) {
+	msg, err := o.message()
+	if err != nil {
+		return nil, false
+	}
+	m, ok := msg.(*DhcpRelayMessage)
+	return m, ok
+}
+
 // Authentication Option
 type AuthOption struct {
 	Protocol                  byte
@@ -613,7 +1058,7 @@ func (o *AuthOption) UnmarshalBinary(data []byte) error {
 	o.Algorithm = data[5]
 	o.RDM = data[6]
 	copy(o.ReplayDetection[:], data[7:15])
-	o.AuthenticationInformation = data[15 : olen+4]
+	o.AuthenticationInformation = cloneBytes(data[15 : olen+4])
 	return nil
 }
 
@@ -626,8 +1071,8 @@ func (o *UnicastOption) Code() OptionCode {
 	return OptionCodeUnicast
 }
 func (o *UnicastOption) MarshalBinary() ([]byte, error) {
-	if len(o.ServerAddress) != net.IPv6len {
-		return nil, ErrInvalidIpv6Address
+	if err := checkIpv6Address(o.ServerAddress); err != nil {
+		return nil, err
 	}
 	data := make([]byte, 20)
 	binary.BigEndian.PutUint16(data, uint16(OptionCodeUnicast))
@@ -645,13 +1090,27 @@ func (o *UnicastOption) UnmarshalBinary(data []byte) error {
 	if binary.BigEndian.Uint16(data[2:]) != net.IPv6len {
 		return ErrInvalidData
 	}
-	o.ServerAddress = data[4:20]
+	o.ServerAddress = net.IP(cloneBytes(data[4:20]))
 	return nil
 }
 
+// Addr returns the server address as a netip.Addr, or the zero Addr if it is
+// not set.
+func (o *UnicastOption) Addr() netip.Addr {
+	addr, _ := netip.AddrFromSlice(o.ServerAddress)
+	return addr
+}
+
+// SetAddr sets the server address from a netip.Addr, always in its 16-byte
+// form.
+func (o *UnicastOption) SetAddr(addr netip.Addr) {
+	ip := addr.As16()
+	o.ServerAddress = net.IP(ip[:])
+}
+
 // Status Code Option
 type StatusCodeOption struct {
-	StatusCode    uint16
+	StatusCode    StatusCode
 	StatusMessage string
 }
 
@@ -659,16 +1118,18 @@ func (o *StatusCodeOption) Code() OptionCode {
 	return OptionCodeStatusCode
 }
 func (o *StatusCodeOption) MarshalBinary() ([]byte, error) {
-	msgData := []byte(o.StatusMessage)
-	if len(msgData) > 65534 {
+	return o.AppendBinary(make([]byte, 0, 6+len(o.StatusMessage)))
+}
+
+// AppendBinary appends the wire-format of the option to data.
+func (o *StatusCodeOption) AppendBinary(data []byte) ([]byte, error) {
+	if len(o.StatusMessage) > 65533 {
 		return nil, ErrWontFit
 	}
-	data := make([]byte, 6+len(msgData))
-	binary.BigEndian.PutUint16(data, uint16(OptionCodeStatusCode))
-	binary.BigEndian.PutUint16(data[2:], uint16(len(msgData)+2))
-	binary.BigEndian.PutUint16(data[4:], o.StatusCode)
-	copy(data[6:], msgData)
-	return data, nil
+	data = binary.BigEndian.AppendUint16(data, uint16(OptionCodeStatusCode))
+	data = binary.BigEndian.AppendUint16(data, uint16(len(o.StatusMessage)+2))
+	data = binary.BigEndian.AppendUint16(data, uint16(o.StatusCode))
+	return append(data, o.StatusMessage...), nil
 }
 func (o *StatusCodeOption) UnmarshalBinary(data []byte) error {
 	if len(data) < 6 {
@@ -678,10 +1139,13 @@ func (o *StatusCodeOption) UnmarshalBinary(data []byte) error {
 		return ErrInvalidType
 	}
 	olen := binary.BigEndian.Uint16(data[2:])
+	if olen < 2 {
+		return ErrInvalidData
+	}
 	if len(data) < int(olen)+4 {
 		return ErrUnexpectedEOF
 	}
-	o.StatusCode = binary.BigEndian.Uint16(data[4:])
+	o.StatusCode = StatusCode(binary.BigEndian.Uint16(data[4:]))
 	o.StatusMessage = string(data[6 : olen+4])
 	return nil
 }
@@ -693,10 +1157,13 @@ func (o *RapidCommitOption) Code() OptionCode {
 	return OptionCodeRapidCommit
 }
 func (o *RapidCommitOption) MarshalBinary() ([]byte, error) {
-	data := make([]byte, 4)
-	binary.BigEndian.PutUint16(data, uint16(OptionCodeRapidCommit))
-	binary.BigEndian.PutUint16(data[2:], 0)
-	return data, nil
+	return o.AppendBinary(make([]byte, 0, 4))
+}
+
+// AppendBinary appends the wire-format of the option to data.
+func (o *RapidCommitOption) AppendBinary(data []byte) ([]byte, error) {
+	data = binary.BigEndian.AppendUint16(data, uint16(OptionCodeRapidCommit))
+	return binary.BigEndian.AppendUint16(data, 0), nil
 }
 func (o *RapidCommitOption) UnmarshalBinary(data []byte) error {
 	if len(data) < 4 {
@@ -753,7 +1220,7 @@ func (o *UserClassOption) UnmarshalBinary(data []byte) error {
 	data = data[4:]
 	for len(data) > 0 {
 		size := binary.BigEndian.Uint16(data)
-		o.UserClassData = append(o.UserClassData, data[2:size+2])
+		o.UserClassData = append(o.UserClassData, cloneBytes(data[2:size+2]))
 		data = data[size+2:]
 	}
 	return nil
@@ -801,7 +1268,7 @@ func (o *VendorClassOption) UnmarshalBinary(data []byte) error {
 	data = data[4:]
 	for len(data) > 0 {
 		size := binary.BigEndian.Uint16(data)
-		o.VendorClassData = append(o.VendorClassData, data[2:size+2])
+		o.VendorClassData = append(o.VendorClassData, cloneBytes(data[2:size+2]))
 		data = data[size+2:]
 	}
 	return nil
@@ -864,18 +1331,79 @@ func (o *VendorOptsOption) UnmarshalBinary(data []byte) error {
 		if len(data) < int(optLen)+4 {
 			return ErrUnexpectedEOF
 		}
-		optData.OptionData = data[4 : optLen+4]
+		optData.OptionData = cloneBytes(data[4 : optLen+4])
 		o.OptionData = append(o.OptionData, optData)
 		data = data[optLen+4:]
 	}
 	return nil
 }
 
+// vendorOptionKey identifies a sub-option within the namespace of the
+// enterprise that defines it.
+type vendorOptionKey struct {
+	enterprise uint32
+	code       uint16
+}
+
+var vendorOptions = map[vendorOptionKey]func() encoding.BinaryUnmarshaler{}
+
+// RegisterVendorOption will decode sub-option code of the Vendor-specific
+// Information options of enterprise using the BinaryUnmarshaler returned by
+// factory (see VendorOptsOption.Decode). Registering a code again replaces
+// the previous factory. It is not safe to register sub-options while options
+// are being decoded.
+func RegisterVendorOption(enterprise uint32, code uint16, factory func() encoding.BinaryUnmarshaler) {
+	vendorOptions[vendorOptionKey{enterprise, code}] = factory
+}
+
+// Decode decodes each sub-option of o, by code. Sub-options registered for
+// the enterprise of o (see RegisterVendorOption) are unmarshaled from their
+// data alone, without the code and length, while the others are returned as
+// a copy of their raw data ([]byte).
+//
+// A sub-option appearing more than once results in ErrDuplicateOption.
+func (o *VendorOptsOption) Decode() (map[uint16]interface{}, error) {
+	decoded := make(map[uint16]interface{}, len(o.OptionData))
+	for _, d := range o.OptionData {
+		if _, ok := decoded[d.OptionCode]; ok {
+			return nil, fmt.Errorf("%w: vendor sub-option %d of enterprise %d", ErrDuplicateOption, d.OptionCode, o.EnterpriseNumber)
+		}
+		factory, ok := vendorOptions[vendorOptionKey{o.EnterpriseNumber, d.OptionCode}]
+		if !ok {
+			decoded[d.OptionCode] = cloneBytes(d.OptionData)
+			continue
+		}
+		v := factory()
+		if err := v.UnmarshalBinary(d.OptionData); err != nil {
+			return nil, fmt.Errorf("%w: vendor sub-option %d of enterprise %d", err, d.OptionCode, o.EnterpriseNumber)
+		}
+		decoded[d.OptionCode] = v
+	}
+	return decoded, nil
+}
+
 // Interface-Id Option
+//
+// Ids longer than MaxInterfaceIdLen are accepted when decoding, but rejected
+// by strict decoding and by NewInterfaceId.
 type InterfaceIdOption struct {
 	InterfaceId []byte
 }
 
+// MaxInterfaceIdLen is the longest interface id considered plausible. Relay
+// agents typically use an interface name or index, far shorter than the 65535
+// octets an option could hold.
+const MaxInterfaceIdLen = 128
+
+// NewInterfaceId will create an InterfaceIdOption carrying a copy of id,
+// returning ErrInvalidData if it is longer than MaxInterfaceIdLen.
+func NewInterfaceId(id []byte) (*InterfaceIdOption, error) {
+	if len(id) > MaxInterfaceIdLen {
+		return nil, fmt.Errorf("%w: interface id is %d octets long, at most %d expected", ErrInvalidData, len(id), MaxInterfaceIdLen)
+	}
+	return &InterfaceIdOption{InterfaceId: cloneBytes(id)}, nil
+}
+
 func (o *InterfaceIdOption) Code() OptionCode {
 	return OptionCodeInterfaceId
 }
@@ -900,7 +1428,13 @@ func (o *InterfaceIdOption) UnmarshalBinary(data []byte) error {
 	if len(data) < int(olen)+4 {
 		return ErrUnexpectedEOF
 	}
-	o.InterfaceId = data[4 : olen+4]
+	o.InterfaceId = cloneBytes(data[4 : olen+4])
+	return nil
+}
+func (o *InterfaceIdOption) anomalies() []string {
+	if len(o.InterfaceId) > MaxInterfaceIdLen {
+		return []string{fmt.Sprintf("Interface-Id option is %d octets long", len(o.InterfaceId))}
+	}
 	return nil
 }
 
@@ -976,8 +1510,8 @@ func (o *NextHopOption) MarshalBinary() ([]byte, error) {
 		data = make([]byte, 20, 65539) //65535+4
 	}
 	binary.BigEndian.PutUint16(data, uint16(OptionCodeNextHop))
-	if len(o.NextHop) != net.IPv6len {
-		return nil, ErrInvalidIpv6Address
+	if err := checkIpv6Address(o.NextHop); err != nil {
+		return nil, err
 	}
 	copy(data[4:20], o.NextHop[0:net.IPv6len])
 
@@ -986,7 +1520,7 @@ func (o *NextHopOption) MarshalBinary() ([]byte, error) {
 		if err != nil {
 			return nil, err
 		}
-		if len(data)+len(optionData) > cap(data) {
+		if len(data)+len(optionData) > 65539 {
 			return nil, ErrWontFit
 		}
 		data = append(data, optionData...)
@@ -996,18 +1530,21 @@ func (o *NextHopOption) MarshalBinary() ([]byte, error) {
 }
 
 func (o *NextHopOption) UnmarshalBinary(data []byte) error {
-	if len(data) < 20 {
+	if len(data) < 4 {
 		return ErrUnexpectedEOF
 	}
 	if binary.BigEndian.Uint16(data) != uint16(OptionCodeNextHop) {
 		return ErrInvalidType
 	}
 	olen := binary.BigEndian.Uint16(data[2:])
+	if olen < 16 {
+		return ErrInvalidData
+	}
 	if len(data) < int(olen)+4 {
 		return ErrUnexpectedEOF
 	}
-	o.NextHop = net.IP(data[4:20])
-	if len(data) == 20 {
+	o.NextHop = net.IP(cloneBytes(data[4:20]))
+	if olen+4 == 20 {
 		o.NextHopOptions = make([]Option, 0)
 	} else {
 		//TODO: better way to guess capacity?
@@ -1019,6 +1556,12 @@ func (o *NextHopOption) UnmarshalBinary(data []byte) error {
 			return ErrUnexpectedEOF
 		}
 		nextSize := binary.BigEndian.Uint16(optionData[2:])
+		if len(optionData) < int(nextSize)+4 {
+			return ErrUnexpectedEOF
+		}
+		if len(o.NextHopOptions) == DefaultMaxOptions {
+			return ErrTooManyOptions
+		}
 		option, err := UnmarshalBinaryOption(optionData[:nextSize+4])
 		if err != nil {
 			return err
@@ -1078,14 +1621,433 @@ func (o *RtPrefixOption) UnmarshalBinary(data []byte) error {
 	o.Lifetime = binary.BigEndian.Uint32(data[4:])
 	o.Prefixlen = data[8]
 	o.Metric = data[9]
-	o.Prefix = net.IP(data[10:])
+	o.Prefix = net.IP(cloneBytes(data[10:]))
+	return nil
+}
+
+// SetInfiniteLifetimes marks the route as never expiring.
+func (o *RtPrefixOption) SetInfiniteLifetimes() {
+	o.Lifetime = InfiniteLifetime
+}
+
+// DNS Recursive Name Server Option
+//
+// An empty list of servers is valid, and is a way for a server to clear the
+// configuration of a client.
+//
+// https://tools.ietf.org/html/rfc3646#section-3
+type DnsServersOption struct {
+	Servers []net.IP
+}
+
+// NewDnsServers will create a DnsServersOption, ensuring each address is in
+// its 16-byte form.
+func NewDnsServers(servers ...net.IP) (*DnsServersOption, error) {
+	for _, ip := range servers {
+		if len(ip) != net.IPv6len {
+			return nil, ErrInvalidIpv6Address
+		}
+	}
+	return &DnsServersOption{Servers: servers}, nil
+}
+
+func (o *DnsServersOption) Code() OptionCode {
+	return OptionCodeDnsServers
+}
+
+// IsEmpty reports whether the option carries no servers.
+func (o *DnsServersOption) IsEmpty() bool {
+	return len(o.Servers) == 0
+}
+func (o *DnsServersOption) MarshalBinary() ([]byte, error) {
+	return o.AppendBinary(make([]byte, 0, 4+len(o.Servers)*net.IPv6len))
+}
+
+// AppendBinary appends the wire-format of the option to data.
+func (o *DnsServersOption) AppendBinary(data []byte) ([]byte, error) {
+	if len(o.Servers) > 4095 { //65535/16
+		return nil, ErrWontFit
+	}
+	data = binary.BigEndian.AppendUint16(data, uint16(OptionCodeDnsServers))
+	data = binary.BigEndian.AppendUint16(data, uint16(len(o.Servers)*net.IPv6len))
+	for _, ip := range o.Servers {
+		if len(ip) != net.IPv6len {
+			return nil, ErrInvalidIpv6Address
+		}
+		data = append(data, ip...)
+	}
+	return data, nil
+}
+func (o *DnsServersOption) UnmarshalBinary(data []byte) error {
+	if len(data) < 4 {
+		return ErrUnexpectedEOF
+	}
+	if binary.BigEndian.Uint16(data) != uint16(OptionCodeDnsServers) {
+		return ErrInvalidType
+	}
+	olen := binary.BigEndian.Uint16(data[2:])
+	if olen%net.IPv6len != 0 {
+		return ErrInvalidData
+	}
+	if len(data) < int(olen)+4 {
+		return ErrUnexpectedEOF
+	}
+	o.Servers = make([]net.IP, olen/net.IPv6len)
+	for i := range o.Servers {
+		o.Servers[i] = net.IP(cloneBytes(data[4+i*net.IPv6len : 4+(i+1)*net.IPv6len]))
+	}
+	return nil
+}
+
+// Domain Search List Option
+//
+// A list too long for a single option results in ErrWontFit from
+// MarshalBinary. Within a message, such a list is instead split across several
+// consecutive options (RFC 3396), each holding as many whole names as fit, and
+// decoding the message joins them back together (see concatenate).
+//
+// https://tools.ietf.org/html/rfc3646#section-4
+type DomainSearchListOption struct {
+	DomainNames []string
+}
+
+func (o *DomainSearchListOption) Code() OptionCode {
+	return OptionCodeDomainList
+}
+func (o *DomainSearchListOption) MarshalBinary() ([]byte, error) {
+	data, err := o.appendSplit(make([]byte, 0, 64))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > 65539 {
+		return nil, ErrWontFit
+	}
+	return data, nil
+}
+
+// appendSplit appends the list to data as one or more consecutive options,
+// splitting it where a single option can not hold it.
+func (o *DomainSearchListOption) appendSplit(data []byte) ([]byte, error) {
+	start := len(data) //offset of the option being filled
+	data = append(data, 0, 0, 0, 0)
+	binary.BigEndian.PutUint16(data[start:], uint16(OptionCodeDomainList))
+	for _, name := range o.DomainNames {
+		nameData, err := EncodeDomainName(name)
+		if err != nil {
+			return nil, err
+		}
+		if len(data)-start-4+len(nameData) > 65535 {
+			binary.BigEndian.PutUint16(data[start+2:], uint16(len(data)-start-4))
+			start = len(data)
+			data = append(data, 0, 0, 0, 0)
+			binary.BigEndian.PutUint16(data[start:], uint16(OptionCodeDomainList))
+		}
+		data = append(data, nameData...)
+	}
+	binary.BigEndian.PutUint16(data[start+2:], uint16(len(data)-start-4))
+	return data, nil
+}
+func (o *DomainSearchListOption) UnmarshalBinary(data []byte) error {
+	if len(data) < 4 {
+		return ErrUnexpectedEOF
+	}
+	if binary.BigEndian.Uint16(data) != uint16(OptionCodeDomainList) {
+		return ErrInvalidType
+	}
+	olen := binary.BigEndian.Uint16(data[2:])
+	if len(data) < int(olen)+4 {
+		return ErrUnexpectedEOF
+	}
+	o.DomainNames = make([]string, 0, 2)
+	data = data[4 : olen+4]
+	for len(data) > 0 {
+		name, n, err := DecodeDomainName(data)
+		if err != nil {
+			return err
+		}
+		o.DomainNames = append(o.DomainNames, name)
+		data = data[n:]
+	}
+	return nil
+}
+
+// concatenate appends the names of next to o if next continues a list that
+// MarshalBinary had to split, that is if the first name of next would not
+// have fit in o. It reports whether next was consumed.
+func (o *DomainSearchListOption) concatenate(next *DomainSearchListOption) bool {
+	if len(next.DomainNames) == 0 {
+		return false
+	}
+	size := encodedDomainNameLen(next.DomainNames[0])
+	for _, name := range o.DomainNames {
+		size += encodedDomainNameLen(name)
+	}
+	if size <= 65535 {
+		return false
+	}
+	o.DomainNames = append(o.DomainNames, next.DomainNames...)
+	return true
+}
+
+// Information Refresh Time Option
+//
+// The refresh time is in seconds, Infinity meaning the client should never
+// refresh its configuration.
+//
+// https://tools.ietf.org/html/rfc4242
+type InformationRefreshTimeOption struct {
+	RefreshTime uint32
+}
+
+func (o *InformationRefreshTimeOption) Code() OptionCode {
+	return OptionCodeInformationRefreshTime
+}
+func (o *InformationRefreshTimeOption) MarshalBinary() ([]byte, error) {
+	return o.AppendBinary(make([]byte, 0, 8))
+}
+
+// AppendBinary appends the wire-format of the option to data.
+func (o *InformationRefreshTimeOption) AppendBinary(data []byte) ([]byte, error) {
+	data = binary.BigEndian.AppendUint16(data, uint16(OptionCodeInformationRefreshTime))
+	data = binary.BigEndian.AppendUint16(data, 4)
+	return binary.BigEndian.AppendUint32(data, o.RefreshTime), nil
+}
+func (o *InformationRefreshTimeOption) UnmarshalBinary(data []byte) error {
+	if len(data) < 8 {
+		return ErrUnexpectedEOF
+	}
+	if binary.BigEndian.Uint16(data) != uint16(OptionCodeInformationRefreshTime) {
+		return ErrInvalidType
+	}
+	if binary.BigEndian.Uint16(data[2:]) != 4 {
+		return ErrInvalidData
+	}
+	o.RefreshTime = binary.BigEndian.Uint32(data[4:])
+	return nil
+}
+
+// NTP Server Option
+//
+// https://tools.ietf.org/html/rfc5908
+type NtpServerOption struct {
+	Suboptions []NtpSuboption
+}
+
+const (
+	//NTP Server suboption types
+	NtpSuboptionSrvAddr = 1
+	NtpSuboptionMcAddr  = 2
+	NtpSuboptionSrvFqdn = 3
+)
+
+// NtpSuboption locates a single time source. Address is used by the
+// NtpSuboptionSrvAddr and NtpSuboptionMcAddr types, and Fqdn by
+// NtpSuboptionSrvFqdn.
+type NtpSuboption struct {
+	Type    uint16
+	Address net.IP
+	Fqdn    string
+}
+
+func (o *NtpServerOption) Code() OptionCode {
+	return OptionCodeNtpServer
+}
+func (o *NtpServerOption) MarshalBinary() ([]byte, error) {
+	data := make([]byte, 4, 64)
+	binary.BigEndian.PutUint16(data, uint16(OptionCodeNtpServer))
+	for _, v := range o.Suboptions {
+		var subData []byte
+		switch v.Type {
+		case NtpSuboptionSrvAddr, NtpSuboptionMcAddr:
+			if err := checkIpv6Address(v.Address); err != nil {
+				return nil, err
+			}
+			subData = v.Address
+		case NtpSuboptionSrvFqdn:
+			nameData, err := EncodeDomainName(v.Fqdn)
+			if err != nil {
+				return nil, err
+			}
+			subData = nameData
+		default:
+			return nil, ErrInvalidType
+		}
+		data = append(data, 0, 0, 0, 0)
+		binary.BigEndian.PutUint16(data[len(data)-4:], v.Type)
+		binary.BigEndian.PutUint16(data[len(data)-2:], uint16(len(subData)))
+		data = append(data, subData...)
+	}
+	if len(data)-4 > 65535 {
+		return nil, ErrWontFit
+	}
+	binary.BigEndian.PutUint16(data[2:], uint16(len(data)-4))
+	return data, nil
+}
+func (o *NtpServerOption) UnmarshalBinary(data []byte) error {
+	if len(data) < 4 {
+		return ErrUnexpectedEOF
+	}
+	if binary.BigEndian.Uint16(data) != uint16(OptionCodeNtpServer) {
+		return ErrInvalidType
+	}
+	olen := binary.BigEndian.Uint16(data[2:])
+	if len(data) < int(olen)+4 {
+		return ErrUnexpectedEOF
+	}
+	o.Suboptions = make([]NtpSuboption, 0, 1)
+	data = data[4 : olen+4]
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return ErrUnexpectedEOF
+		}
+		sub := NtpSuboption{Type: binary.BigEndian.Uint16(data)}
+		subLen := int(binary.BigEndian.Uint16(data[2:]))
+		if len(data) < subLen+4 {
+			return ErrUnexpectedEOF
+		}
+		subData := data[4 : subLen+4]
+		switch sub.Type {
+		case NtpSuboptionSrvAddr, NtpSuboptionMcAddr:
+			if subLen != net.IPv6len {
+				return ErrInvalidData
+			}
+			sub.Address = net.IP(cloneBytes(subData))
+		case NtpSuboptionSrvFqdn:
+			name, n, err := DecodeDomainName(subData)
+			if err != nil {
+				return err
+			}
+			if n != subLen {
+				return ErrInvalidData
+			}
+			sub.Fqdn = name
+		default:
+			return ErrInvalidType
+		}
+		o.Suboptions = append(o.Suboptions, sub)
+		data = data[subLen+4:]
+	}
+	return nil
+}
+
+// Relay Agent Remote-ID Option
+//
+// https://tools.ietf.org/html/rfc4649
+type RemoteIdOption struct {
+	EnterpriseNumber uint32
+	RemoteId         []byte
+}
+
+func (o *RemoteIdOption) Code() OptionCode {
+	return OptionCodeRemoteId
+}
+func (o *RemoteIdOption) MarshalBinary() ([]byte, error) {
+	if len(o.RemoteId) > 65531 { //65535-4
+		return nil, ErrWontFit
+	}
+	data := make([]byte, 8+len(o.RemoteId))
+	binary.BigEndian.PutUint16(data, uint16(OptionCodeRemoteId))
+	binary.BigEndian.PutUint16(data[2:], uint16(4+len(o.RemoteId)))
+	binary.BigEndian.PutUint32(data[4:], o.EnterpriseNumber)
+	copy(data[8:], o.RemoteId)
+	return data, nil
+}
+func (o *RemoteIdOption) UnmarshalBinary(data []byte) error {
+	if len(data) < 8 {
+		return ErrUnexpectedEOF
+	}
+	if binary.BigEndian.Uint16(data) != uint16(OptionCodeRemoteId) {
+		return ErrInvalidType
+	}
+	olen := binary.BigEndian.Uint16(data[2:])
+	if olen < 4 {
+		return ErrInvalidData
+	}
+	if len(data) < int(olen)+4 {
+		return ErrUnexpectedEOF
+	}
+	o.EnterpriseNumber = binary.BigEndian.Uint32(data[4:])
+	o.RemoteId = cloneBytes(data[8 : olen+4])
+	return nil
+}
+
+// Relay Agent Subscriber-ID Option
+//
+// https://tools.ietf.org/html/rfc4580
+type SubscriberIdOption struct {
+	SubscriberId []byte
+}
+
+func (o *SubscriberIdOption) Code() OptionCode {
+	return OptionCodeSubscriberId
+}
+func (o *SubscriberIdOption) MarshalBinary() ([]byte, error) {
+	if len(o.SubscriberId) > 65535 {
+		return nil, ErrWontFit
+	}
+	data := make([]byte, 4+len(o.SubscriberId))
+	binary.BigEndian.PutUint16(data, uint16(OptionCodeSubscriberId))
+	binary.BigEndian.PutUint16(data[2:], uint16(len(o.SubscriberId)))
+	copy(data[4:], o.SubscriberId)
+	return data, nil
+}
+func (o *SubscriberIdOption) UnmarshalBinary(data []byte) error {
+	if len(data) < 4 {
+		return ErrUnexpectedEOF
+	}
+	if binary.BigEndian.Uint16(data) != uint16(OptionCodeSubscriberId) {
+		return ErrInvalidType
+	}
+	olen := binary.BigEndian.Uint16(data[2:])
+	if len(data) < int(olen)+4 {
+		return ErrUnexpectedEOF
+	}
+	o.SubscriberId = cloneBytes(data[4 : olen+4])
 	return nil
 }
 
 // FQDN Option
+//
+// When decoding, partial names (sent without the terminating root label) are
+// accepted, and Partial is set so that the name is marshaled the same way.
+//
+// https://tools.ietf.org/html/rfc4704
 type FQDNOption struct {
 	Flags      uint8
 	DomainName string
+
+	// Partial marks a name sent without the terminating root label
+	// (RFC 4704 section 4.2), such as a bare host name.
+	Partial bool
+
+	// set when decoding the legacy form without the flags byte
+	legacy bool
+}
+
+const (
+	//FQDN flags (RFC 4704 section 4.1)
+	FQDNFlagS = 0x01 //the server should perform the AAAA RR update
+	FQDNFlagO = 0x02 //the server has overridden the client's preference for S
+	FQDNFlagN = 0x04 //the server should not perform any DNS updates
+)
+
+// NewFQDN will create an FQDNOption for name, which must encode in the DNS
+// wire format (see EncodeDomainName). A trailing dot on name is dropped, and
+// an empty name (the root) returns ErrInvalidData.
+func NewFQDN(name string, flags uint8) (*FQDNOption, error) {
+	name = strings.TrimSuffix(name, ".")
+	if name == "" {
+		return nil, fmt.Errorf("%w: empty domain name", ErrInvalidData)
+	}
+	if _, err := EncodeDomainName(name); err != nil {
+		return nil, fmt.Errorf("%w: domain name %q", err, name)
+	}
+	return &FQDNOption{Flags: flags, DomainName: name}, nil
+}
+
+// Fqdn returns the domain name carried by the option, without a trailing dot.
+func (o *FQDNOption) Fqdn() string {
+	return strings.TrimSuffix(o.DomainName, ".")
 }
 
 func (o *FQDNOption) Code() OptionCode {
@@ -1093,11 +2055,18 @@ func (o *FQDNOption) Code() OptionCode {
 }
 
 func (o *FQDNOption) MarshalBinary() ([]byte, error) {
-	data := make([]byte, 4 + 1 + len(o.DomainName))
+	nameData, err := EncodeDomainName(o.DomainName)
+	if err != nil {
+		return nil, err
+	}
+	if o.Partial {
+		nameData = nameData[:len(nameData)-1]
+	}
+	data := make([]byte, 4+1+len(nameData))
 	binary.BigEndian.PutUint16(data, uint16(OptionCodeFQDN))
-	binary.BigEndian.PutUint16(data[2:], uint16(1 + len(o.DomainName)))
+	binary.BigEndian.PutUint16(data[2:], uint16(1+len(nameData)))
 	data[4] = o.Flags
-	copy(data[5:], o.DomainName)
+	copy(data[5:], nameData)
 
 	return data, nil
 }
@@ -1110,14 +2079,59 @@ func (o *FQDNOption) UnmarshalBinary(data []byte) error {
 		return ErrInvalidType
 	}
 	olen := binary.BigEndian.Uint16(data[2:])
-	if len(data) < int(olen) + 4 {
+	if olen < 1 {
+		return ErrInvalidData
+	}
+	if len(data) < int(olen)+4 {
 		return ErrUnexpectedEOF
 	}
-//	o.Flags = data[4] ???
-	o.DomainName = string(data[4 : olen + 4])
+	o.legacy = false
+
+	// Some early clients omit the flags byte entirely, so the option is
+	// parsed both ways. The compliant form wins unless it fails to parse or
+	// has flags outside the low 3 bits, in which case the legacy form is used
+	// if the whole option parses as a name.
+	name, n, err := decodePartialDomainName(data[5 : olen+4])
+	compliant := err == nil && n == int(olen)-1
+	if !compliant || data[4]&0xf8 != 0 {
+		legacyName, n, legacyErr := decodePartialDomainName(data[4 : olen+4])
+		if legacyErr == nil && n == int(olen) {
+			o.Flags = 0
+			o.DomainName = legacyName
+			o.Partial = data[olen+3] != 0
+			o.legacy = true
+			return nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if !compliant {
+		return ErrInvalidData
+	}
+	o.Flags = data[4]
+	o.DomainName = name
+	o.Partial = olen == 1 || data[olen+3] != 0
 	return nil
 }
 
+func (o *FQDNOption) anomalies() []string {
+	if o.legacy {
+		return []string{"FQDN option is missing the flags byte"}
+	}
+	return nil
+}
+
+// decodePartialDomainName decodes a name that may be missing its
+// terminating root label, as allowed by the FQDN option.
+func decodePartialDomainName(data []byte) (string, int, error) {
+	if len(data) == 0 || data[len(data)-1] != 0 {
+		name, n, err := DecodeDomainName(append(append([]byte{}, data...), 0))
+		return name, n - 1, err
+	}
+	return DecodeDomainName(data)
+}
+
 // MTU Option
 type MTUOption struct {
 	MTU	uint16
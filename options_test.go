@@ -0,0 +1,928 @@
+package dhcpv6
+
+import (
+	"bytes"
+	"encoding"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatusCodeOption_UnmarshalBinary(t *testing.T) {
+	o := new(StatusCodeOption)
+	assert.NoError(t, o.UnmarshalBinary([]byte{0x00, 0x0d, 0x00, 0x02, 0x00, 0x02}))
+	assert.Equal(t, &StatusCodeOption{StatusCode: 2}, o)
+	data, err := o.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x00, 0x0d, 0x00, 0x02, 0x00, 0x02}, data)
+
+	withMessage := []byte{0x00, 0x0d, 0x00, 0x04, 0x01, 0x02, 'o', 'k'}
+	assert.NoError(t, o.UnmarshalBinary(withMessage))
+	assert.Equal(t, &StatusCodeOption{StatusCode: 258, StatusMessage: "ok"}, o)
+	data, err = o.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, withMessage, data)
+
+	//a declared length too short for the code
+	assert.Equal(t, ErrInvalidData, o.UnmarshalBinary([]byte{0x00, 0x0d, 0x00, 0x00, 0x00, 0x0e, 0x00, 0x00}))
+	assert.Equal(t, ErrUnexpectedEOF, o.UnmarshalBinary([]byte{0x00, 0x0d, 0x00, 0x01, 0x00}))
+}
+
+func TestRemoteIdOption_MarshalBinary(t *testing.T) {
+	o := &RemoteIdOption{
+		EnterpriseNumber: 3561,
+		RemoteId:         []byte{0x01, 0x02, 0x03},
+	}
+	expected := []byte{0x00, 0x25, 0x00, 0x07, 0x00, 0x00, 0x0d, 0xe9, 0x01, 0x02, 0x03}
+	actual, err := o.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, expected, actual)
+}
+func TestRemoteIdOption_UnmarshalBinary(t *testing.T) {
+	o := new(RemoteIdOption)
+	err := o.UnmarshalBinary([]byte{0x00, 0x25, 0x00, 0x02, 0x00, 0x00, 0x0d, 0xe9})
+	assert.Error(t, err, "option shorter than the enterprise number")
+
+	data := []byte{0x00, 0x25, 0x00, 0x07, 0x00, 0x00, 0x0d, 0xe9, 0x01, 0x02, 0x03}
+	option, err := UnmarshalBinaryOption(data)
+	assert.NoError(t, err)
+	if assert.IsType(t, &RemoteIdOption{}, option) {
+		o = option.(*RemoteIdOption)
+		assert.Equal(t, uint32(3561), o.EnterpriseNumber)
+		assert.Equal(t, []byte{0x01, 0x02, 0x03}, o.RemoteId)
+	}
+	actual, err := option.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, data, actual)
+}
+
+func TestSubscriberIdOption_UnmarshalBinary(t *testing.T) {
+	data := append([]byte{0x00, 0x26, 0x00, 0x0a}, "circuit-42"...)
+	option, err := UnmarshalBinaryOption(data)
+	assert.NoError(t, err)
+	if assert.IsType(t, &SubscriberIdOption{}, option) {
+		o := option.(*SubscriberIdOption)
+		assert.Equal(t, []byte("circuit-42"), o.SubscriberId)
+		assert.Equal(t, "SUBSCRIBER_ID circuit-42", o.String())
+	}
+	actual, err := option.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, data, actual)
+
+	data = []byte{0x00, 0x26, 0x00, 0x03, 0x00, 0xff, 0x10}
+	option, err = UnmarshalBinaryOption(data)
+	assert.NoError(t, err)
+	if assert.IsType(t, &SubscriberIdOption{}, option) {
+		assert.Equal(t, "SUBSCRIBER_ID 00ff10", option.(*SubscriberIdOption).String())
+	}
+	actual, err = option.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, data, actual)
+}
+
+func TestOroOption_UnmarshalBinary(t *testing.T) {
+	o := new(OroOption)
+	err := o.UnmarshalBinary([]byte{0x00, 0x06, 0x00, 0x06, 0x00, 0x17, 0x00, 0x18, 0x00, 0x38})
+	assert.NoError(t, err)
+	assert.Equal(t, []uint16{23, 24, 56}, o.RequestedOptionCodes)
+
+	err = o.UnmarshalBinary([]byte{0x00, 0x06, 0x00, 0x03, 0x00, 0x17, 0x00})
+	assert.Equal(t, ErrInvalidData, err, "odd length")
+
+	err = o.UnmarshalBinary([]byte{0x00, 0x06, 0xff, 0xfe, 0x00, 0x17})
+	assert.Equal(t, ErrUnexpectedEOF, err, "length exceeds buffer")
+}
+
+func TestElapsedTimeSince(t *testing.T) {
+	defer func(now func() time.Time) { Now = now }(Now)
+	start := time.Date(2015, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	Now = func() time.Time { return start.Add(1500 * time.Millisecond) }
+	assert.Equal(t, uint16(150), ElapsedTimeSince(start).ElapsedTime)
+
+	Now = func() time.Time { return start.Add(time.Hour) }
+	assert.Equal(t, uint16(0xffff), ElapsedTimeSince(start).ElapsedTime, "clamp to the maximum")
+
+	Now = func() time.Time { return start.Add(-time.Second) }
+	assert.Equal(t, uint16(0), ElapsedTimeSince(start).ElapsedTime, "clock went backwards")
+}
+
+func TestIaNaOption_EffectiveTimers(t *testing.T) {
+	o := &IaNaOption{T1: 100, T2: 200}
+	t1, t2 := o.EffectiveTimers(1000)
+	assert.Equal(t, uint32(100), t1)
+	assert.Equal(t, uint32(200), t2)
+
+	o = &IaNaOption{}
+	t1, t2 = o.EffectiveTimers(1000)
+	assert.Equal(t, uint32(500), t1)
+	assert.Equal(t, uint32(800), t2)
+
+	t1, t2 = o.EffectiveTimers(Infinity)
+	assert.Equal(t, uint32(InfiniteLifetime), t1)
+	assert.Equal(t, uint32(InfiniteLifetime), t2)
+}
+
+func TestIaNaOption_UnmarshalBinary(t *testing.T) {
+	header := []byte{0x00, 0x03, 0x00, 0x10, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x07, 0x08, 0x00, 0x00, 0x0b, 0x40}
+
+	//IA_ADDR sub-option with a declared length of 0
+	zeroAddr := append(append([]byte{}, header...), 0x00, 0x05, 0x00, 0x00)
+	o := new(IaNaOption)
+	assert.Equal(t, ErrInvalidData, o.UnmarshalBinary(zeroAddr))
+
+	//the same, followed by a sibling sub-option that must not be read
+	zeroAddr = append(append([]byte{}, header...), 0x00, 0x05, 0x00, 0x00, 0x00, 0x0e, 0x00, 0x00)
+	zeroAddr[3] = 0x14
+	assert.Equal(t, ErrInvalidData, o.UnmarshalBinary(zeroAddr))
+
+	//sub-option claiming more data than the IA_NA holds
+	overrun := append(append([]byte{}, header...), 0x00, 0x0e, 0x00, 0x08)
+	assert.Equal(t, ErrUnexpectedEOF, o.UnmarshalBinary(overrun))
+
+	zeroIa := append([]byte{0x00, 0x03, 0x00, 0x00}, header[4:]...)
+	assert.Equal(t, ErrInvalidData, o.UnmarshalBinary(zeroIa))
+}
+
+func TestInformationRefreshTimeOption_UnmarshalBinary(t *testing.T) {
+	data := []byte{0x00, 0x20, 0x00, 0x04, 0x00, 0x00, 0x0e, 0x10}
+	option, err := UnmarshalBinaryOption(data)
+	assert.NoError(t, err)
+	assert.Equal(t, &InformationRefreshTimeOption{RefreshTime: 3600}, option)
+	actual, err := option.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, data, actual)
+
+	infinite, err := (&InformationRefreshTimeOption{RefreshTime: Infinity}).MarshalBinary()
+	assert.NoError(t, err)
+	option, err = UnmarshalBinaryOption(infinite)
+	assert.NoError(t, err)
+	assert.Equal(t, &InformationRefreshTimeOption{RefreshTime: Infinity}, option)
+
+	o := new(InformationRefreshTimeOption)
+	assert.Equal(t, ErrInvalidData, o.UnmarshalBinary([]byte{0x00, 0x20, 0x00, 0x02, 0x0e, 0x10, 0x00, 0x00}), "short payload")
+	assert.Equal(t, ErrInvalidData, o.UnmarshalBinary([]byte{0x00, 0x20, 0x00, 0x05, 0x00, 0x00, 0x0e, 0x10, 0x00}), "long payload")
+	assert.Equal(t, ErrUnexpectedEOF, o.UnmarshalBinary(data[:6]))
+}
+
+func TestIaAddrOption_SetInfiniteLifetimes(t *testing.T) {
+	o := &IaAddrOption{PreferredLifetime: 100, ValidLifetime: 200}
+	o.SetInfiniteLifetimes()
+	assert.Equal(t, uint32(0xffffffff), o.PreferredLifetime)
+	assert.Equal(t, uint32(0xffffffff), o.ValidLifetime)
+}
+
+func TestIaPrefixOption_SetInfiniteLifetimes(t *testing.T) {
+	o := &IaPrefixOption{PreferredLifetime: 100, ValidLifetime: 200}
+	o.SetInfiniteLifetimes()
+	assert.Equal(t, uint32(0xffffffff), o.PreferredLifetime)
+	assert.Equal(t, uint32(0xffffffff), o.ValidLifetime)
+}
+
+func TestRtPrefixOption_SetInfiniteLifetimes(t *testing.T) {
+	o := &RtPrefixOption{Lifetime: 100}
+	o.SetInfiniteLifetimes()
+	assert.Equal(t, uint32(0xffffffff), o.Lifetime)
+}
+
+func TestDnsServersOption(t *testing.T) {
+	servers := []net.IP{
+		net.ParseIP("2001:4860:4860::8888"),
+		net.ParseIP("2001:4860:4860::8844"),
+		net.ParseIP("2606:4700:4700::1111"),
+	}
+	for _, n := range []int{0, 1, 3} {
+		o, err := NewDnsServers(servers[:n]...)
+		assert.NoError(t, err)
+		assert.Equal(t, n == 0, o.IsEmpty())
+		data, err := o.MarshalBinary()
+		assert.NoError(t, err)
+
+		decoded := new(DnsServersOption)
+		assert.NoError(t, decoded.UnmarshalBinary(data))
+		assert.Equal(t, n == 0, decoded.IsEmpty())
+	}
+
+	_, err := NewDnsServers(net.ParseIP("2001:db8::1"), net.IPv4(192, 0, 2, 1).To4())
+	assert.Equal(t, ErrInvalidIpv6Address, err)
+}
+
+func TestDnsServersOption_MarshalBinary(t *testing.T) {
+	o := &DnsServersOption{Servers: []net.IP{net.ParseIP("2001:db8::53")}}
+	data, err := o.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{
+		0x00, 0x17, 0x00, 0x10,
+		0x20, 0x01, 0x0d, 0xb8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x53,
+	}, data)
+
+	data, err = new(DnsServersOption).MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x00, 0x17, 0x00, 0x00}, data)
+}
+
+func TestDnsServersOption_UnmarshalBinary(t *testing.T) {
+	servers := []net.IP{
+		net.ParseIP("2001:4860:4860::8888"),
+		net.ParseIP("2001:4860:4860::8844"),
+		net.ParseIP("2606:4700:4700::1111"),
+	}
+	for _, n := range []int{0, 1, 3} {
+		data, err := (&DnsServersOption{Servers: servers[:n]}).MarshalBinary()
+		assert.NoError(t, err)
+		assert.Len(t, data, 4+16*n)
+
+		option, err := UnmarshalBinaryOption(data)
+		assert.NoError(t, err)
+		if assert.IsType(t, &DnsServersOption{}, option) {
+			decoded := option.(*DnsServersOption)
+			assert.Len(t, decoded.Servers, n)
+			for i := range decoded.Servers {
+				assert.True(t, servers[i].Equal(decoded.Servers[i]))
+			}
+		}
+	}
+
+	_, err := (&DnsServersOption{Servers: []net.IP{{0x01}}}).MarshalBinary()
+	assert.Equal(t, ErrInvalidIpv6Address, err)
+	err = new(DnsServersOption).UnmarshalBinary([]byte{0x00, 0x17, 0x00, 0x04, 0x20, 0x01, 0x0d, 0xb8})
+	assert.Equal(t, ErrInvalidData, err)
+}
+
+func TestIaAddrOption_UnmarshalBinary(t *testing.T) {
+	data := []byte{
+		0x00, 0x05, 0x00, 0x18,
+		0x20, 0x01, 0x0d, 0xb8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x0e, 0x10,
+		0x00, 0x00, 0x1c, 0x20,
+	}
+	sibling := []byte{0x00, 0x0e, 0x00, 0x00}
+
+	o := new(IaAddrOption)
+	assert.NoError(t, o.UnmarshalBinary(append(data, sibling...)))
+	assert.True(t, net.ParseIP("2001:db8::1").Equal(o.Ipv6Address))
+	assert.Equal(t, uint32(3600), o.PreferredLifetime)
+	assert.Equal(t, uint32(7200), o.ValidLifetime)
+	assert.Empty(t, o.IAddrOptions, "sibling must not be parsed as a sub-option")
+	assert.Equal(t, 0, cap(o.IAddrOptions))
+
+	d := new(DhcpMessage)
+	assert.NoError(t, d.UnmarshalBinary(append(append([]byte{byte(TypeReply), 0x01, 0x02, 0x03}, data...), sibling...)))
+	if assert.Len(t, d.Options, 2) {
+		assert.Empty(t, d.Options[0].(*IaAddrOption).IAddrOptions)
+		assert.IsType(t, &RapidCommitOption{}, d.Options[1])
+	}
+
+	//with a status code sub-option, the sibling must still be left alone
+	withSub := append([]byte{}, data...)
+	withSub[3] = 0x1e
+	withSub = append(withSub, 0x00, 0x0d, 0x00, 0x02, 0x00, 0x00)
+	o = new(IaAddrOption)
+	assert.NoError(t, o.UnmarshalBinary(append(withSub, sibling...)))
+	if assert.Len(t, o.IAddrOptions, 1) {
+		assert.IsType(t, &StatusCodeOption{}, o.IAddrOptions[0])
+	}
+
+	//a declared length of 0 leaves no room for the address and lifetimes
+	zeroLength := make([]byte, 32)
+	copy(zeroLength, []byte{0x00, 0x05, 0x00, 0x00})
+	assert.Equal(t, ErrInvalidData, new(IaAddrOption).UnmarshalBinary(zeroLength))
+}
+
+func TestIaAddrOption_MarshalBinary(t *testing.T) {
+	_, err := new(IaAddrOption).MarshalBinary()
+	assert.Equal(t, ErrIpv6AddressNotSet, err)
+	_, err = (&IaAddrOption{Ipv6Address: net.IPv4(192, 0, 2, 1).To4()}).MarshalBinary()
+	assert.Equal(t, ErrInvalidIpv6Address, err)
+
+	//16 sub-options of 4004 octets each (64064 in total)
+	o := &IaAddrOption{Ipv6Address: net.ParseIP("2001:db8::1")}
+	for i := 0; i < 16; i++ {
+		o.IAddrOptions = append(o.IAddrOptions, &UnknownOption{OptionCode: 1000, OptionData: make([]byte, 4000)})
+	}
+	data, err := o.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Len(t, data, 28+16*4004)
+
+	//24 octets of address and lifetimes leave room for 65511 octets of sub-options
+	o.IAddrOptions = []Option{&UnknownOption{OptionCode: 1000, OptionData: make([]byte, 65507)}}
+	data, err = o.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x00, 0x05, 0xff, 0xff}, data[:4])
+	o.IAddrOptions[0].(*UnknownOption).OptionData = make([]byte, 65508)
+	_, err = o.MarshalBinary()
+	assert.Equal(t, ErrWontFit, err)
+}
+
+func TestUnicastOption_MarshalBinary(t *testing.T) {
+	_, err := new(UnicastOption).MarshalBinary()
+	assert.Equal(t, ErrIpv6AddressNotSet, err)
+	_, err = (&UnicastOption{ServerAddress: net.IP{0x01, 0x02}}).MarshalBinary()
+	assert.Equal(t, ErrInvalidIpv6Address, err)
+}
+
+func TestNextHopOption_MarshalBinary(t *testing.T) {
+	_, err := new(NextHopOption).MarshalBinary()
+	assert.Equal(t, ErrIpv6AddressNotSet, err)
+	_, err = (&NextHopOption{NextHop: net.IP{0x01, 0x02}}).MarshalBinary()
+	assert.Equal(t, ErrInvalidIpv6Address, err)
+
+	//16 octets of address leave room for 65519 octets of sub-options
+	o := &NextHopOption{
+		NextHop:        net.ParseIP("2001:db8::1"),
+		NextHopOptions: []Option{&UnknownOption{OptionCode: 1000, OptionData: make([]byte, 65515)}},
+	}
+	data, err := o.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x00, 0xf2, 0xff, 0xff}, data[:4])
+	o.NextHopOptions[0].(*UnknownOption).OptionData = make([]byte, 65516)
+	_, err = o.MarshalBinary()
+	assert.Equal(t, ErrWontFit, err)
+}
+
+func TestRegisteredOptionCodes(t *testing.T) {
+	options := []Option{
+		&ClientIdOption{}, &ServerIdOption{}, &IaNaOption{}, &IaTaOption{}, &IaAddrOption{},
+		&OroOption{}, &PreferenceOption{}, &ElapsedTimeOption{}, &RelayMsgOption{}, &AuthOption{},
+		&UnicastOption{}, &StatusCodeOption{}, &RapidCommitOption{}, &UserClassOption{},
+		&VendorClassOption{}, &VendorOptsOption{}, &InterfaceIdOption{}, &ReconfMsgOption{},
+		&ReconfAcceptOption{}, &DnsServersOption{}, &InformationRefreshTimeOption{},
+		&RemoteIdOption{}, &SubscriberIdOption{}, &FQDNOption{}, &NextHopOption{},
+		&RtPrefixOption{}, &MTUOption{}, &S46RuleOption{}, &S46ContMapEOption{},
+		&S46ContMapTOption{}, &S46ContLwOption{}, &S46BrOption{}, &DomainSearchListOption{},
+		&NtpServerOption{}, &IaPdOption{}, &IaPrefixOption{},
+	}
+	codes := RegisteredOptionCodes()
+	assert.Len(t, codes, len(options))
+	for _, o := range options {
+		assert.NotEmpty(t, codes[o.Code()], "option %d", o.Code())
+		assert.IsType(t, o, NewOptionByCode(o.Code()))
+	}
+	assert.Equal(t, "IA_NA", codes[OptionCodeIaNa])
+}
+
+func TestNewOptionByCode(t *testing.T) {
+	assert.Equal(t, &ElapsedTimeOption{}, NewOptionByCode(OptionCodeElapsedTime))
+	assert.Equal(t, &UnknownOption{OptionCode: 1000}, NewOptionByCode(1000))
+}
+
+type experimentalOption struct {
+	code OptionCode
+	Data []byte
+}
+
+func (o *experimentalOption) Code() OptionCode { return o.code }
+func (o *experimentalOption) MarshalBinary() ([]byte, error) {
+	return (&UnknownOption{OptionCode: o.code, OptionData: o.Data}).MarshalBinary()
+}
+func (o *experimentalOption) UnmarshalBinary(data []byte) error {
+	u := &UnknownOption{}
+	if err := u.UnmarshalBinary(data); err != nil {
+		return err
+	}
+	o.code, o.Data = u.OptionCode, u.OptionData
+	return nil
+}
+
+// vendorVersion is a vendor sub-option carrying a two-octet version.
+type vendorVersion struct {
+	Major, Minor byte
+}
+
+func (v *vendorVersion) UnmarshalBinary(data []byte) error {
+	if len(data) != 2 {
+		return ErrInvalidData
+	}
+	v.Major, v.Minor = data[0], data[1]
+	return nil
+}
+
+func TestVendorOptsOption_Decode(t *testing.T) {
+	defer func(saved map[vendorOptionKey]func() encoding.BinaryUnmarshaler) { vendorOptions = saved }(vendorOptions)
+	vendorOptions = map[vendorOptionKey]func() encoding.BinaryUnmarshaler{}
+	RegisterVendorOption(4491, 1, func() encoding.BinaryUnmarshaler { return new(vendorVersion) })
+
+	data := []byte{
+		0x00, 0x11, 0x00, 0x0f, 0x00, 0x00, 0x11, 0x8b,
+		0x00, 0x01, 0x00, 0x02, 0x03, 0x01,
+		0x00, 0x02, 0x00, 0x01, 0xaa,
+	}
+	o := new(VendorOptsOption)
+	assert.NoError(t, o.UnmarshalBinary(data))
+	decoded, err := o.Decode()
+	assert.NoError(t, err)
+	assert.Equal(t, map[uint16]interface{}{
+		1: &vendorVersion{Major: 3, Minor: 1},
+		2: []byte{0xaa},
+	}, decoded)
+
+	//codes are scoped to the enterprise that registered them
+	o.EnterpriseNumber = 4492
+	decoded, err = o.Decode()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x03, 0x01}, decoded[1])
+
+	o.EnterpriseNumber = 4491
+	o.OptionData[0].OptionData = []byte{0x03}
+	_, err = o.Decode()
+	assert.True(t, errors.Is(err, ErrInvalidData))
+
+	o.OptionData = []VendorOptsOptionData{{OptionCode: 2}, {OptionCode: 2}}
+	_, err = o.Decode()
+	assert.True(t, errors.Is(err, ErrDuplicateOption))
+}
+
+func TestRegisterOptionRange(t *testing.T) {
+	defer func(saved []optionRange) { optionRanges = saved }(optionRanges)
+	RegisterOptionRange(65000, 65100, func(code OptionCode) Option {
+		return &experimentalOption{code: code}
+	})
+
+	o, err := UnmarshalBinaryOption([]byte{0xfd, 0xe8, 0x00, 0x01, 0xaa})
+	assert.NoError(t, err)
+	assert.Equal(t, &experimentalOption{code: 65000, Data: []byte{0xaa}}, o)
+
+	o, err = UnmarshalBinaryOption([]byte{0xfe, 0x4c, 0x00, 0x00})
+	assert.NoError(t, err)
+	assert.Equal(t, &experimentalOption{code: 65100, Data: []byte{}}, o)
+
+	assert.IsType(t, &UnknownOption{}, NewOptionByCode(65101))
+
+	//exact matches take precedence over ranges
+	RegisterOptionRange(1, 100, func(code OptionCode) Option {
+		return &experimentalOption{code: code}
+	})
+	assert.IsType(t, &ClientIdOption{}, NewOptionByCode(OptionCodeClientId))
+	assert.IsType(t, &experimentalOption{}, NewOptionByCode(99))
+}
+
+func TestFQDNOption_MarshalBinary(t *testing.T) {
+	o := &FQDNOption{Flags: FQDNFlagS, DomainName: "host.example.com"}
+	data, err := o.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{
+		0x00, 0x27, 0x00, 0x13,
+		0x01,
+		0x04, 'h', 'o', 's', 't', 0x07, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 0x03, 'c', 'o', 'm', 0x00,
+	}, data)
+
+	decoded, err := UnmarshalBinaryOption(data)
+	assert.NoError(t, err)
+	assert.Equal(t, o, decoded)
+
+	o = &FQDNOption{Flags: FQDNFlagO | FQDNFlagN, DomainName: "host.example.com."}
+	data, err = o.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, byte(0x06), data[4])
+}
+
+func TestNewFQDN(t *testing.T) {
+	o, err := NewFQDN("host.example.com.", FQDNFlagS)
+	assert.NoError(t, err)
+	assert.Equal(t, "host.example.com", o.Fqdn())
+	assert.Equal(t, uint8(FQDNFlagS), o.Flags)
+	data, err := o.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x04, 'h', 'o', 's', 't'}, data[5:10])
+
+	_, err = NewFQDN(strings.Repeat("a", 64)+".example.com", 0)
+	assert.ErrorIs(t, err, ErrInvalidData, "label over 63 octets")
+	_, err = NewFQDN("host..example.com", 0)
+	assert.ErrorIs(t, err, ErrInvalidData, "empty label")
+	_, err = NewFQDN(strings.Repeat(strings.Repeat("a", 63)+".", 4), 0)
+	assert.ErrorIs(t, err, ErrWontFit, "name over 255 octets")
+	_, err = NewFQDN("", 0)
+	assert.ErrorIs(t, err, ErrInvalidData)
+	_, err = NewFQDN(".", 0)
+	assert.ErrorIs(t, err, ErrInvalidData)
+}
+
+func TestFQDNOption_UnmarshalBinary(t *testing.T) {
+	name := []byte{0x04, 'h', 'o', 's', 't', 0x07, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 0x03, 'c', 'o', 'm', 0x00}
+
+	compliant := append([]byte{0x00, 0x27, 0x00, byte(len(name) + 1), 0x01}, name...)
+	o := new(FQDNOption)
+	assert.NoError(t, o.UnmarshalBinary(compliant))
+	assert.Equal(t, uint8(0x01), o.Flags)
+	assert.Equal(t, "host.example.com", o.DomainName)
+	assert.Empty(t, o.anomalies())
+
+	partial := []byte{0x00, 0x27, 0x00, 0x06, 0x00, 0x04, 'h', 'o', 's', 't'}
+	assert.NoError(t, o.UnmarshalBinary(partial))
+	assert.Equal(t, uint8(0), o.Flags)
+	assert.Equal(t, "host", o.DomainName)
+	assert.True(t, o.Partial)
+	data, err := o.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, partial, data, "a partial name stays partial")
+
+	//the first label length (9) is not a plausible flags byte
+	legacyName := append([]byte{0x09, 'h', 'o', 's', 't', 'n', 'a', 'm', 'e', '1'}, name[5:]...)
+	legacy := append([]byte{0x00, 0x27, 0x00, byte(len(legacyName))}, legacyName...)
+	assert.NoError(t, o.UnmarshalBinary(legacy))
+	assert.Equal(t, uint8(0), o.Flags)
+	assert.Equal(t, "hostname1.example.com", o.DomainName)
+	assert.NotEmpty(t, o.anomalies())
+
+	//a first label length (4) that is also a plausible flags byte
+	legacy = append([]byte{0x00, 0x27, 0x00, byte(len(name))}, name...)
+	assert.NoError(t, o.UnmarshalBinary(legacy))
+	assert.Equal(t, uint8(0), o.Flags)
+	assert.Equal(t, "host.example.com", o.DomainName)
+	assert.False(t, o.Partial)
+	assert.NotEmpty(t, o.anomalies())
+
+	//a bare host name in the legacy form is marshaled as a partial name
+	assert.NoError(t, o.UnmarshalBinary([]byte{0x00, 0x27, 0x00, 0x05, 0x04, 'h', 'o', 's', 't'}))
+	assert.Equal(t, "host", o.DomainName)
+	assert.True(t, o.Partial)
+	data, err = o.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, partial, data)
+
+	legacy = append([]byte{0x00, 0x27, 0x00, byte(len(legacyName))}, legacyName...)
+	msg := append([]byte{byte(TypeSolicit), 0x01, 0x02, 0x03}, legacy...)
+	d, err := DecodeConfig{}.DecodeMessage(msg)
+	assert.NoError(t, err, "lenient mode accepts the legacy form")
+	assert.Equal(t, "hostname1.example.com", d.Options[0].(*FQDNOption).DomainName)
+	_, err = DecodeConfig{Strict: true}.DecodeMessage(msg)
+	assert.True(t, errors.Is(err, ErrInvalidData), "strict mode rejects the legacy form")
+
+	//a Solicit must also carry a Client Identifier to pass in strict mode
+	clientId := []byte{0x00, 0x01, 0x00, 0x0a, 0x00, 0x03, 0x00, 0x01, 0x00, 0x00, 0x5e, 0x00, 0x53, 0x01}
+	msg = append(append([]byte{byte(TypeSolicit), 0x01, 0x02, 0x03}, clientId...), compliant...)
+	_, err = DecodeConfig{Strict: true}.DecodeMessage(msg)
+	assert.NoError(t, err)
+}
+
+func TestRelayMsgOption_ClientMessage(t *testing.T) {
+	solicit := &DhcpMessage{MsgType: TypeSolicit, TransactionId: [3]byte{1, 2, 3}, Options: []Option{}}
+	data, err := (&RelayMsgOption{DhcpRelayMessage: solicit}).MarshalBinary()
+	assert.NoError(t, err)
+	o := new(RelayMsgOption)
+	assert.NoError(t, o.UnmarshalBinary(data))
+
+	msg, ok := o.ClientMessage()
+	assert.True(t, ok)
+	assert.Equal(t, TypeSolicit, msg.MsgType)
+	assert.Equal(t, [3]byte{1, 2, 3}, msg.TransactionId)
+	_, ok = o.RelayMessage()
+	assert.False(t, ok)
+
+	o = &RelayMsgOption{RawInner: data[4:]}
+	msg, ok = o.ClientMessage()
+	assert.True(t, ok, "RawInner is decoded")
+	assert.Equal(t, TypeSolicit, msg.MsgType)
+
+	//RawInner is what gets written, so it is also what gets read
+	o = &RelayMsgOption{DhcpRelayMessage: &DhcpMessage{MsgType: TypeRequest}, RawInner: data[4:]}
+	msg, ok = o.ClientMessage()
+	assert.True(t, ok)
+	assert.Equal(t, TypeSolicit, msg.MsgType)
+
+	_, ok = (&RelayMsgOption{}).ClientMessage()
+	assert.False(t, ok)
+	_, ok = (&RelayMsgOption{RawInner: []byte{byte(TypeSolicit)}}).ClientMessage()
+	assert.False(t, ok, "malformed RawInner")
+	_, ok = (&RelayMsgOption{DhcpRelayMessage: solicit, RawInner: []byte{byte(TypeSolicit)}}).ClientMessage()
+	assert.False(t, ok, "malformed RawInner hides DhcpRelayMessage")
+}
+
+func TestRelayMsgOption_RelayMessage(t *testing.T) {
+	inner := &DhcpRelayMessage{
+		MsgType:     TypeRelayForward,
+		LinkAddress: net.ParseIP("2001:db8::1"),
+		PeerAddress: net.ParseIP("fe80::1"),
+		Options:     []Option{&RelayMsgOption{DhcpRelayMessage: &DhcpMessage{MsgType: TypeSolicit}}},
+	}
+	data, err := (&RelayMsgOption{DhcpRelayMessage: inner}).MarshalBinary()
+	assert.NoError(t, err)
+	o := new(RelayMsgOption)
+	assert.NoError(t, o.UnmarshalBinary(data))
+
+	msg, ok := o.RelayMessage()
+	assert.True(t, ok)
+	assert.Equal(t, TypeRelayForward, msg.MsgType)
+	_, ok = o.ClientMessage()
+	assert.False(t, ok)
+}
+
+func TestIaAddrOption_Addr(t *testing.T) {
+	o := new(IaAddrOption)
+	assert.False(t, o.Addr().IsValid())
+
+	o.Ipv6Address = net.ParseIP("2001:db8::1")
+	assert.Equal(t, netip.MustParseAddr("2001:db8::1"), o.Addr())
+
+	o.SetAddr(netip.IPv6Unspecified())
+	assert.Equal(t, net.IPv6unspecified, o.Ipv6Address)
+	assert.Equal(t, netip.IPv6Unspecified(), o.Addr())
+
+	o.SetAddr(netip.MustParseAddr("2001:db8::2"))
+	assert.Len(t, o.Ipv6Address, 16)
+	assert.True(t, net.ParseIP("2001:db8::2").Equal(o.Ipv6Address))
+}
+
+func TestUnicastOption_Addr(t *testing.T) {
+	o := &UnicastOption{ServerAddress: net.ParseIP("2001:db8::53")}
+	assert.Equal(t, netip.MustParseAddr("2001:db8::53"), o.Addr())
+
+	o.SetAddr(netip.MustParseAddr("fe80::1"))
+	assert.True(t, net.ParseIP("fe80::1").Equal(o.ServerAddress))
+	_, err := o.MarshalBinary()
+	assert.NoError(t, err)
+}
+
+func TestIaNaOption_MarshalBinary(t *testing.T) {
+	o := &IaNaOption{
+		IAID: [4]byte{0xaf, 0xaa, 0xac, 0xa3},
+		IaNaOptions: []Option{
+			&UnknownOption{OptionCode: 1000, OptionData: make([]byte, 40000)},
+			&UnknownOption{OptionCode: 1000, OptionData: make([]byte, 40000)},
+		},
+	}
+	_, err := o.MarshalBinary()
+	assert.Equal(t, ErrWontFit, err, "length would overflow the 16-bit length field")
+
+	o.IaNaOptions = o.IaNaOptions[:1]
+	data, err := o.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x00, 0x03, 0x9c, 0x50}, data[:4])
+}
+
+func TestIaNaOption_MarshalBinary_timers(t *testing.T) {
+	_, err := (&IaNaOption{T1: 2880, T2: 1800}).MarshalBinary()
+	assert.Equal(t, ErrInvalidData, err, "T1 > T2")
+	_, err = (&IaNaOption{T1: 1800}).MarshalBinary()
+	assert.NoError(t, err, "T2 of 0 is left to the client")
+	_, err = (&IaNaOption{}).MarshalBinary()
+	assert.NoError(t, err)
+
+	_, err = (&IaPdOption{T1: 2880, T2: 1800}).MarshalBinary()
+	assert.Equal(t, ErrInvalidData, err, "T1 > T2")
+	_, err = (&IaPdOption{}).MarshalBinary()
+	assert.NoError(t, err)
+}
+
+func TestIaTaOption_MarshalBinary(t *testing.T) {
+	o := &IaTaOption{
+		IaTaOptions: []Option{
+			&UnknownOption{OptionCode: 1000, OptionData: make([]byte, 65528)},
+		},
+	}
+	_, err := o.MarshalBinary()
+	assert.Equal(t, ErrWontFit, err)
+
+	o.IaTaOptions[0].(*UnknownOption).OptionData = make([]byte, 65527)
+	data, err := o.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x00, 0x04, 0xff, 0xff}, data[:4])
+}
+
+func TestIaPdOption_MarshalBinary(t *testing.T) {
+	//12 octets of IAID and timers leave room for 65523 octets of sub-options
+	o := &IaPdOption{
+		IaPdOptions: []Option{&UnknownOption{OptionCode: 1000, OptionData: make([]byte, 65519)}},
+	}
+	data, err := o.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x00, 0x19, 0xff, 0xff}, data[:4])
+	o.IaPdOptions[0].(*UnknownOption).OptionData = make([]byte, 65520)
+	_, err = o.MarshalBinary()
+	assert.Equal(t, ErrWontFit, err)
+}
+
+func TestIaPrefixOption_MarshalBinary(t *testing.T) {
+	//25 octets of lifetimes and prefix leave room for 65510 octets of sub-options
+	o := &IaPrefixOption{
+		PrefixLength:    56,
+		Ipv6Prefix:      net.ParseIP("2001:db8::"),
+		IaPrefixOptions: []Option{&UnknownOption{OptionCode: 1000, OptionData: make([]byte, 65506)}},
+	}
+	data, err := o.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x00, 0x1a, 0xff, 0xff}, data[:4])
+	o.IaPrefixOptions[0].(*UnknownOption).OptionData = make([]byte, 65507)
+	_, err = o.MarshalBinary()
+	assert.Equal(t, ErrWontFit, err)
+}
+
+func TestDomainSearchListOption_MarshalBinary(t *testing.T) {
+	//each name encodes to 197 octets, so 400 of them need two options
+	label := strings.Repeat("a", 63)
+	names := make([]string, 400)
+	for i := range names {
+		names[i] = fmt.Sprintf("%03d.%s.%s.%s", i, label, label, label)
+	}
+	o := &DomainSearchListOption{DomainNames: names}
+	_, err := o.MarshalBinary()
+	assert.Equal(t, ErrWontFit, err, "a single option can not hold the list")
+
+	d := &DhcpMessage{MsgType: TypeReply, Options: []Option{o, &RapidCommitOption{}}}
+	data, err := d.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, data[4:], mustMarshal(t, &DhcpRelayMessage{
+		MsgType:     TypeRelayReply,
+		LinkAddress: net.IPv6zero,
+		PeerAddress: net.IPv6zero,
+		Options:     d.Options,
+	})[34:], "relay messages split the list too")
+	buf := new(bytes.Buffer)
+	_, err = d.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, data, buf.Bytes())
+
+	spans, err := ScanOptions(data)
+	assert.NoError(t, err)
+	if assert.Len(t, spans, 3) {
+		assert.Equal(t, OptionCodeDomainList, spans[0].Code)
+		assert.Equal(t, 4+332*197, spans[0].Length, "as many names as fit in the first option")
+		assert.Equal(t, OptionCodeDomainList, spans[1].Code)
+		assert.Equal(t, 4+68*197, spans[1].Length)
+	}
+
+	decoded := new(DhcpMessage)
+	assert.NoError(t, decoded.UnmarshalBinary(data))
+	assert.Equal(t, []Option{o, &RapidCommitOption{}}, decoded.Options)
+
+	//the option itself decodes a single option, and marshals back to it
+	single := new(DomainSearchListOption)
+	assert.NoError(t, single.UnmarshalBinary(data[4:]))
+	assert.Len(t, single.DomainNames, 332)
+	assert.Equal(t, data[4:4+spans[0].Length], mustMarshal(t, single))
+
+	//short lists sent as separate options are kept apart
+	d.Options = []Option{
+		&DomainSearchListOption{DomainNames: []string{"example.com"}},
+		&DomainSearchListOption{DomainNames: []string{"example.net"}},
+	}
+	data, err = d.MarshalBinary()
+	assert.NoError(t, err)
+	assert.NoError(t, decoded.UnmarshalBinary(data))
+	assert.Equal(t, d.Options, decoded.Options)
+}
+
+func TestUnmarshalBinaryOption_truncated(t *testing.T) {
+	_, err := UnmarshalBinaryOption(nil)
+	assert.Equal(t, ErrUnexpectedEOF, err)
+	_, err = UnmarshalBinaryOption([]byte{0x00})
+	assert.Equal(t, ErrUnexpectedEOF, err)
+
+	//decode every truncation of every option in the golden messages; none may panic
+	for name, d := range goldenMessages(t) {
+		for _, o := range d.Options {
+			data, err := o.MarshalBinary()
+			assert.NoError(t, err)
+			for i := range data {
+				assert.NotPanics(t, func() { UnmarshalBinaryOption(data[:i]) }, "%s: option %d truncated to %d", name, o.Code(), i)
+			}
+		}
+	}
+}
+
+func TestIaPdOption_UnmarshalBinary(t *testing.T) {
+	data := []byte{
+		0x00, 0x19, 0x00, 0x29,
+		0x00, 0x00, 0x00, 0x02,
+		0x00, 0x00, 0x07, 0x08,
+		0x00, 0x00, 0x0b, 0x40,
+		0x00, 0x1a, 0x00, 0x19,
+		0x00, 0x00, 0x0e, 0x10,
+		0x00, 0x00, 0x1c, 0x20,
+		0x30,
+		0x20, 0x01, 0x0d, 0xb8, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}
+	expected := &IaPdOption{
+		IAID: [4]byte{0, 0, 0, 2},
+		T1:   1800,
+		T2:   2880,
+		IaPdOptions: []Option{
+			&IaPrefixOption{
+				PreferredLifetime: 3600,
+				ValidLifetime:     7200,
+				PrefixLength:      48,
+				Ipv6Prefix:        net.ParseIP("2001:db8:1::"),
+				IaPrefixOptions:   []Option{},
+			},
+		},
+	}
+	option, err := UnmarshalBinaryOption(data)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, option)
+	assert.Equal(t, netip.MustParsePrefix("2001:db8:1::/48"), expected.IaPdOptions[0].(*IaPrefixOption).Prefix())
+
+	actual, err := option.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, data, actual)
+
+	//zero-length IA_PREFIX
+	zeroPrefix := []byte{0x00, 0x19, 0x00, 0x10, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0x00, 0x1a, 0x00, 0x00}
+	assert.Equal(t, ErrInvalidData, new(IaPdOption).UnmarshalBinary(zeroPrefix))
+
+	_, err = (&IaPrefixOption{Ipv6Prefix: net.ParseIP("2001:db8::"), PrefixLength: 129}).MarshalBinary()
+	assert.Equal(t, ErrInvalidData, err)
+	_, err = new(IaPrefixOption).MarshalBinary()
+	assert.Equal(t, ErrIpv6AddressNotSet, err)
+}
+
+func TestNewInterfaceId(t *testing.T) {
+	id := []byte("eth0")
+	o, err := NewInterfaceId(id)
+	assert.NoError(t, err)
+	id[0] = 'x'
+	assert.Equal(t, []byte("eth0"), o.InterfaceId, "the id is copied")
+
+	_, err = NewInterfaceId(make([]byte, MaxInterfaceIdLen))
+	assert.NoError(t, err)
+	_, err = NewInterfaceId(make([]byte, MaxInterfaceIdLen+1))
+	assert.True(t, errors.Is(err, ErrInvalidData))
+}
+
+func TestInterfaceIdOption_UnmarshalBinary(t *testing.T) {
+	buf := []byte{0x00, 0x12, 0x00, 0x04, 'e', 't', 'h', '0'}
+	o := new(InterfaceIdOption)
+	assert.NoError(t, o.UnmarshalBinary(buf))
+	copy(buf[4:], "wlan")
+	assert.Equal(t, []byte("eth0"), o.InterfaceId, "the decoded id is independent of the buffer")
+
+	//long ids are only rejected by strict decoding
+	relay := relayMessage(0, exampleSolicit())
+	relay.Options[0] = &InterfaceIdOption{InterfaceId: make([]byte, MaxInterfaceIdLen+1)}
+	data, err := relay.MarshalBinary()
+	assert.NoError(t, err)
+	_, err = DecodeConfig{}.DecodeRelayMessage(data)
+	assert.NoError(t, err)
+	_, err = DecodeConfig{Strict: true}.DecodeRelayMessage(data)
+	assert.True(t, errors.Is(err, ErrInvalidData))
+}
+
+func TestNtpServerOption_UnmarshalBinary(t *testing.T) {
+	//FQDN sub-option carrying "ntp.example.com" in DNS wire format
+	data := []byte{0x00, 0x38, 0x00, 0x15, 0x00, 0x03, 0x00, 0x11,
+		3, 'n', 't', 'p', 7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0}
+	o := new(NtpServerOption)
+	assert.NoError(t, o.UnmarshalBinary(data))
+	assert.Equal(t, []NtpSuboption{{Type: NtpSuboptionSrvFqdn, Fqdn: "ntp.example.com"}}, o.Suboptions)
+
+	//compression pointer in place of the name
+	data = []byte{0x00, 0x38, 0x00, 0x06, 0x00, 0x03, 0x00, 0x02, 0xc0, 0x0c}
+	assert.Equal(t, ErrInvalidData, new(NtpServerOption).UnmarshalBinary(data))
+}
+
+func TestNtpServerOption_MarshalBinary(t *testing.T) {
+	//each sub-option takes 20 octets, so 3276 of them fill the option
+	server := NtpSuboption{Type: NtpSuboptionSrvAddr, Address: net.ParseIP("2001:db8::123")}
+	o := &NtpServerOption{Suboptions: make([]NtpSuboption, 3276)}
+	for i := range o.Suboptions {
+		o.Suboptions[i] = server
+	}
+	data, err := o.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Len(t, data, 65524)
+
+	o.Suboptions = append(o.Suboptions, server)
+	_, err = o.MarshalBinary()
+	assert.Equal(t, ErrWontFit, err, "length would overflow the 16-bit length field")
+}
+
+func TestNewClientId(t *testing.T) {
+	mac := net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+	o := NewClientId(&LlDuid{HardwareType: 1, LlAddress: mac})
+	assert.Equal(t, &ClientIdOption{Duid: &LlDuid{HardwareType: 1, LlAddress: mac}}, o)
+	addr, ok := o.HardwareAddr()
+	assert.True(t, ok)
+	assert.Equal(t, mac, addr)
+
+	o = NewClientId(&LltDuid{HardwareType: 1, Time: 1, LlAddress: mac})
+	addr, ok = o.HardwareAddr()
+	assert.True(t, ok)
+	assert.Equal(t, mac, addr)
+
+	o = NewClientId(&EnDuid{EnterpriseNumber: 32473, Identifier: []byte{0x01}})
+	_, ok = o.HardwareAddr()
+	assert.False(t, ok)
+
+	assert.Equal(t, &ServerIdOption{Duid: placeholderServerDuid()}, NewServerId(placeholderServerDuid()))
+}
+
+func TestRelayMsgOption_MarshalBinary(t *testing.T) {
+	//padded, so it would not survive being decoded and re-encoded
+	raw := []byte{byte(TypeSolicit), 0x01, 0x02, 0x03, 0x00, 0x08, 0x00, 0x02, 0x00, 0x64, 0x00, 0x00}
+	o := &RelayMsgOption{RawInner: raw}
+	data, err := o.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, append([]byte{0x00, 0x09, 0x00, 0x0c}, raw...), data)
+
+	o.DhcpRelayMessage = &DhcpMessage{MsgType: TypeAdvertise}
+	data, err = o.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, raw, data[4:], "RawInner takes priority")
+
+	_, err = (&RelayMsgOption{}).MarshalBinary()
+	assert.Equal(t, ErrInvalidData, err)
+}
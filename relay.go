@@ -0,0 +1,209 @@
+package dhcpv6
+
+import (
+	"fmt"
+)
+
+// RelayChain unwinds the relay messages nested within d, returning every
+// relay message from the outermost (d itself) to the innermost, along with
+// the client/server message they carry.
+//
+// Unwinding stops with ErrHopCountExceeded if the chain is deeper than
+// HopCountLimit. Relay Message options carrying pre-encoded bytes are
+// decoded as they are reached. A relay message carrying more than one Relay
+// Message option results in ErrDuplicateOption.
+func (d *DhcpRelayMessage) RelayChain() ([]*DhcpRelayMessage, Message, error) {
+	chain := []*DhcpRelayMessage{d}
+	relay := d
+	for {
+		o, err := relay.relayMsgOption()
+		if err != nil {
+			return nil, nil, err
+		}
+		inner, err := o.message()
+		if err != nil {
+			return nil, nil, err
+		}
+		if inner == nil {
+			return nil, nil, ErrMissingRelayMsg
+		}
+		next, ok := inner.(*DhcpRelayMessage)
+		if !ok {
+			return chain, inner, nil
+		}
+		if len(chain) >= HopCountLimit {
+			return nil, nil, ErrHopCountExceeded
+		}
+		chain = append(chain, next)
+		relay = next
+	}
+}
+
+// BuildRelayReply wraps innerReply (the server's response to the message
+// relayed by forward) in a Relay-Reply message for each Relay-Forward message
+// in the chain of forward, so it can be sent back along the same path.
+//
+// As described in RFC 3315 section 20.3, each Relay-Reply copies the hop count,
+// link-address and peer-address of the matching Relay-Forward, along with its
+// Interface-Id option if present.
+func BuildRelayReply(forward *DhcpRelayMessage, innerReply Message) (*DhcpRelayMessage, error) {
+	chain, _, err := forward.RelayChain()
+	if err != nil {
+		return nil, err
+	}
+	msg := innerReply
+	for i := len(chain) - 1; i >= 0; i-- {
+		if chain[i].MsgType != TypeRelayForward {
+			return nil, ErrInvalidType
+		}
+		reply := &DhcpRelayMessage{
+			MsgType:     TypeRelayReply,
+			HopCount:    chain[i].HopCount,
+			LinkAddress: chain[i].LinkAddress,
+			PeerAddress: chain[i].PeerAddress,
+			Options:     make([]Option, 0, 2),
+		}
+		for _, o := range chain[i].Options {
+			if v, ok := o.(*InterfaceIdOption); ok {
+				reply.Options = append(reply.Options, v)
+				break
+			}
+		}
+		reply.Options = append(reply.Options, &RelayMsgOption{DhcpRelayMessage: msg})
+		msg = reply
+	}
+	return msg.(*DhcpRelayMessage), nil
+}
+
+// RelayOptions returns the options added by the relay agent (such as the
+// Interface-Id, Remote-ID and Subscriber-ID options), that is every option
+// except the Relay Message option.
+func (d *DhcpRelayMessage) RelayOptions() []Option {
+	options := make([]Option, 0, len(d.Options))
+	for _, o := range d.Options {
+		if o.Code() != OptionCodeRelayMsg {
+			options = append(options, o)
+		}
+	}
+	return options
+}
+
+// Encapsulate sets the Relay Message option of d to carry inner, replacing
+// any it had. inner is marshaled once, and carried as pre-encoded bytes (see
+// RelayMsgOption.RawInner), so later changes to inner are not reflected in d.
+// A Message (such as a *DhcpMessage) is also kept as DhcpRelayMessage.
+func (d *DhcpRelayMessage) Encapsulate(inner interface{ MarshalBinary() ([]byte, error) }) error {
+	data, err := inner.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if len(data) > 65535 {
+		return ErrWontFit
+	}
+	o := &RelayMsgOption{RawInner: data}
+	if msg, ok := inner.(Message); ok {
+		o.DhcpRelayMessage = msg
+	}
+	d.ReplaceOption(o)
+	return nil
+}
+
+// relayMsgOption returns the Relay Message option of d, of which RFC 3315
+// section 20.1.1 permits exactly one: ErrMissingRelayMsg is returned if there
+// is none, and ErrDuplicateOption if there are several.
+func (d *DhcpRelayMessage) relayMsgOption() (*RelayMsgOption, error) {
+	var found *RelayMsgOption
+	for _, o := range d.Options {
+		v, ok := o.(*RelayMsgOption)
+		if !ok {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("%w: %v", ErrDuplicateOption, OptionCodeRelayMsg)
+		}
+		found = v
+	}
+	if found == nil {
+		return nil, ErrMissingRelayMsg
+	}
+	return found, nil
+}
+
+// InnerMessage returns the encoded message carried by the Relay Message
+// option of d, or ErrMissingRelayMsg if there is none (ErrDuplicateOption if
+// there are several).
+func (d *DhcpRelayMessage) InnerMessage() ([]byte, error) {
+	o, err := d.relayMsgOption()
+	if err != nil {
+		return nil, err
+	}
+	if o.RawInner != nil {
+		return o.RawInner, nil
+	}
+	if o.DhcpRelayMessage == nil {
+		return nil, ErrMissingRelayMsg
+	}
+	return o.DhcpRelayMessage.MarshalBinary()
+}
+
+// UnwindRelayChain decodes data and peels every relay layer from it (see
+// RelayChain), returning the innermost client/server message along with the
+// relay messages from the outermost inwards. A client/server message that was
+// not relayed is returned with no relay messages.
+//
+// The chain is decoded one relay message at a time, stopping with
+// ErrHopCountExceeded as soon as it is found to be deeper than HopCountLimit.
+func UnwindRelayChain(data []byte) (*DhcpMessage, []*DhcpRelayMessage, error) {
+	var chain []*DhcpRelayMessage
+	var options []*RelayMsgOption
+	for len(data) > 0 && (DhcpMessageType(data[0]) == TypeRelayForward || DhcpMessageType(data[0]) == TypeRelayReply) {
+		if len(chain) == HopCountLimit {
+			return nil, nil, ErrHopCountExceeded
+		}
+		relay := new(DhcpRelayMessage)
+		if _, err := relay.unmarshalRelay(data, true, DefaultMaxOptions); err != nil {
+			return nil, nil, err
+		}
+		o, err := relay.relayMsgOption()
+		if err != nil {
+			return nil, nil, err
+		}
+		chain = append(chain, relay)
+		options = append(options, o)
+		data = o.RawInner
+	}
+	d := new(DhcpMessage)
+	if err := d.UnmarshalBinary(data); err != nil {
+		return nil, nil, err
+	}
+
+	//link each relay message to the one it carries, as UnmarshalBinary would
+	for i, o := range options {
+		o.RawInner = nil
+		if i+1 < len(chain) {
+			o.DhcpRelayMessage = chain[i+1]
+		} else {
+			o.DhcpRelayMessage = d
+		}
+	}
+	return d, chain, nil
+}
+
+// InnerClientId returns the DUID of the Client Identifier option carried by
+// the client message at the bottom of the relay chain of d (see RelayChain,
+// which enforces HopCountLimit).
+func (d *DhcpRelayMessage) InnerClientId() (Duid, error) {
+	_, inner, err := d.RelayChain()
+	if err != nil {
+		return nil, err
+	}
+	msg, ok := inner.(*DhcpMessage)
+	if !ok {
+		return nil, ErrInvalidType
+	}
+	id, ok := msg.ClientId()
+	if !ok {
+		return nil, fmt.Errorf("%w: %v in %v", ErrMissingOption, OptionCodeClientId, msg.MsgType)
+	}
+	return id.Duid, nil
+}
@@ -0,0 +1,252 @@
+package dhcpv6
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+// relayMessage wraps inner in a Relay-Forward message.
+func relayMessage(hopCount byte, inner Message) *DhcpRelayMessage {
+	return &DhcpRelayMessage{
+		MsgType:     TypeRelayForward,
+		HopCount:    hopCount,
+		LinkAddress: net.ParseIP("2001:db8::1"),
+		PeerAddress: net.ParseIP("fe80::1"),
+		Options: []Option{
+			&InterfaceIdOption{InterfaceId: []byte{hopCount}},
+			&RelayMsgOption{DhcpRelayMessage: inner},
+		},
+	}
+}
+
+func TestDhcpRelayMessage_RelayChain(t *testing.T) {
+	client := exampleSolicit()
+	d := relayMessage(2, relayMessage(1, relayMessage(0, client)))
+
+	data, err := d.MarshalBinary()
+	assert.NoError(t, err)
+	decoded := new(DhcpRelayMessage)
+	assert.NoError(t, decoded.UnmarshalBinary(data))
+
+	chain, inner, err := decoded.RelayChain()
+	assert.NoError(t, err)
+	if assert.Len(t, chain, 3) {
+		for i, relay := range chain {
+			assert.Equal(t, byte(2-i), relay.HopCount)
+		}
+	}
+	if assert.IsType(t, &DhcpMessage{}, inner) {
+		assert.Equal(t, client.TransactionId, inner.(*DhcpMessage).TransactionId)
+		assert.Len(t, inner.(*DhcpMessage).Options, len(client.Options))
+	}
+
+	_, _, err = (&DhcpRelayMessage{MsgType: TypeRelayForward}).RelayChain()
+	assert.Equal(t, ErrMissingRelayMsg, err)
+
+	var deep Message = client
+	for i := 0; i <= HopCountLimit+1; i++ {
+		deep = relayMessage(byte(i), deep)
+	}
+	_, _, err = deep.(*DhcpRelayMessage).RelayChain()
+	assert.Equal(t, ErrHopCountExceeded, err)
+}
+
+func TestBuildRelayReply(t *testing.T) {
+	solicit, err := MinimalMessage(TypeSolicit)
+	assert.NoError(t, err)
+	reply, err := MinimalMessage(TypeReply)
+	assert.NoError(t, err)
+
+	forward := relayMessage(0, solicit)
+	r, err := BuildRelayReply(forward, reply)
+	assert.NoError(t, err)
+	assert.Equal(t, &DhcpRelayMessage{
+		MsgType:     TypeRelayReply,
+		HopCount:    0,
+		LinkAddress: forward.LinkAddress,
+		PeerAddress: forward.PeerAddress,
+		Options: []Option{
+			&InterfaceIdOption{InterfaceId: []byte{0}},
+			&RelayMsgOption{DhcpRelayMessage: reply},
+		},
+	}, r)
+
+	forward = relayMessage(1, relayMessage(0, solicit))
+	forward.PeerAddress = net.ParseIP("2001:db8::2")
+	r, err = BuildRelayReply(forward, reply)
+	assert.NoError(t, err)
+	chain, inner, err := r.RelayChain()
+	assert.NoError(t, err)
+	assert.Equal(t, reply, inner)
+	if assert.Len(t, chain, 2) {
+		assert.Equal(t, byte(1), chain[0].HopCount)
+		assert.Equal(t, net.ParseIP("2001:db8::2"), chain[0].PeerAddress)
+		assert.Equal(t, &InterfaceIdOption{InterfaceId: []byte{1}}, chain[0].Options[0])
+		assert.Equal(t, byte(0), chain[1].HopCount)
+		assert.Equal(t, &InterfaceIdOption{InterfaceId: []byte{0}}, chain[1].Options[0])
+		for _, relay := range chain {
+			assert.Equal(t, TypeRelayReply, relay.MsgType)
+		}
+	}
+	_, err = r.MarshalBinary()
+	assert.NoError(t, err)
+
+	//without an Interface-Id option
+	forward = relayMessage(0, solicit)
+	forward.Options = forward.Options[1:]
+	r, err = BuildRelayReply(forward, reply)
+	assert.NoError(t, err)
+	assert.Equal(t, []Option{&RelayMsgOption{DhcpRelayMessage: reply}}, r.Options)
+
+	_, err = BuildRelayReply(&DhcpRelayMessage{MsgType: TypeRelayForward}, reply)
+	assert.Equal(t, ErrMissingRelayMsg, err)
+	_, err = BuildRelayReply(r, reply)
+	assert.Equal(t, ErrInvalidType, err, "a Relay-Reply can not be answered")
+}
+
+func TestDhcpRelayMessage_RelayOptions(t *testing.T) {
+	d := relayMessage(0, exampleSolicit())
+	remoteId := &RemoteIdOption{EnterpriseNumber: 32473, RemoteId: []byte{0x01}}
+	d.Options = append(d.Options, remoteId)
+	assert.Equal(t, []Option{d.Options[0], remoteId}, d.RelayOptions())
+	assert.Len(t, d.Options, 3, "original is untouched")
+
+	d.Options = []Option{&RelayMsgOption{DhcpRelayMessage: exampleSolicit()}}
+	assert.Empty(t, d.RelayOptions())
+}
+
+func TestDhcpRelayMessage_Encapsulate(t *testing.T) {
+	client := exampleSolicit()
+	relay := &DhcpRelayMessage{
+		MsgType:     TypeRelayForward,
+		LinkAddress: net.ParseIP("2001:db8::1"),
+		PeerAddress: net.ParseIP("fe80::1"),
+	}
+	_, err := relay.InnerMessage()
+	assert.Equal(t, ErrMissingRelayMsg, err)
+
+	assert.NoError(t, relay.Encapsulate(client))
+	expected, err := client.MarshalBinary()
+	assert.NoError(t, err)
+	inner, err := relay.InnerMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, expected, inner)
+	assert.Equal(t, &RelayMsgOption{DhcpRelayMessage: client, RawInner: expected}, relay.Options[0])
+
+	//pre-encoded bytes, replacing the message set above
+	raw := goldenMessages(t)["solicit"]
+	assert.NoError(t, relay.Encapsulate(rawMessage(mustMarshal(t, raw))))
+	assert.Len(t, relay.Options, 1)
+	inner, err = relay.InnerMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, mustMarshal(t, raw), inner)
+
+	_, innermost, err := relay.RelayChain()
+	assert.NoError(t, err)
+	assert.Equal(t, raw.TransactionId, innermost.(*DhcpMessage).TransactionId)
+}
+
+func TestUnwindRelayChain(t *testing.T) {
+	client := exampleSolicit()
+	data := mustMarshal(t, relayMessage(1, relayMessage(0, client)))
+	d, chain, err := UnwindRelayChain(data)
+	assert.NoError(t, err)
+	assert.Equal(t, client.TransactionId, d.TransactionId)
+	if assert.Len(t, chain, 2) {
+		assert.Equal(t, byte(1), chain[0].HopCount)
+		assert.Equal(t, byte(0), chain[1].HopCount)
+	}
+
+	d, chain, err = UnwindRelayChain(mustMarshal(t, client))
+	assert.NoError(t, err)
+	assert.Empty(t, chain, "not relayed")
+	assert.Equal(t, client.TransactionId, d.TransactionId)
+
+	var deep Message = client
+	for i := 0; i <= HopCountLimit+1; i++ {
+		deep = relayMessage(byte(i), deep)
+	}
+	_, _, err = UnwindRelayChain(mustMarshal(t, deep))
+	assert.Equal(t, ErrHopCountExceeded, err)
+
+	//the chain is not decoded past the limit, so what lies beyond it is never looked at
+	var malformed Message = relayMessage(0, nil)
+	malformed.(*DhcpRelayMessage).Options[1] = &RelayMsgOption{RawInner: []byte{byte(TypeSolicit), 0x01}}
+	for i := 1; i <= HopCountLimit; i++ {
+		malformed = relayMessage(byte(i), malformed)
+	}
+	_, _, err = UnwindRelayChain(mustMarshal(t, malformed))
+	assert.Equal(t, ErrHopCountExceeded, err)
+
+	//the decoded chain is linked as UnmarshalBinary would
+	msg, err := UnmarshalBinaryMessage(data)
+	assert.NoError(t, err)
+	d, chain, err = UnwindRelayChain(data)
+	assert.NoError(t, err)
+	assert.Equal(t, msg, chain[0])
+	inner, err := chain[1].InnerMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, mustMarshal(t, d), inner)
+}
+
+func TestDhcpRelayMessage_DuplicateRelayMsg(t *testing.T) {
+	relay := relayMessage(1, relayMessage(0, exampleSolicit()))
+	relay.Options = append(relay.Options, &RelayMsgOption{DhcpRelayMessage: &DhcpMessage{MsgType: TypeRequest}})
+
+	_, _, err := relay.RelayChain()
+	assert.True(t, errors.Is(err, ErrDuplicateOption))
+	_, err = relay.InnerMessage()
+	assert.True(t, errors.Is(err, ErrDuplicateOption))
+	_, _, err = UnwindRelayChain(mustMarshal(t, relay))
+	assert.True(t, errors.Is(err, ErrDuplicateOption))
+
+	//relay messages deeper in the chain are checked as well
+	inner := relayMessage(0, exampleSolicit())
+	inner.Options = append(inner.Options, &RelayMsgOption{RawInner: mustMarshal(t, exampleSolicit())})
+	_, _, err = relayMessage(1, inner).RelayChain()
+	assert.True(t, errors.Is(err, ErrDuplicateOption))
+}
+
+// rawMessage is an already-encoded message.
+type rawMessage []byte
+
+func (m rawMessage) MarshalBinary() ([]byte, error) {
+	return m, nil
+}
+
+func mustMarshal(t *testing.T, m interface{ MarshalBinary() ([]byte, error) }) []byte {
+	data, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDhcpRelayMessage_InnerClientId(t *testing.T) {
+	client := exampleSolicit()
+	clientId, _ := client.ClientId()
+
+	for hops, relay := range []*DhcpRelayMessage{
+		relayMessage(0, client),
+		relayMessage(1, relayMessage(0, client)),
+	} {
+		decoded := new(DhcpRelayMessage)
+		assert.NoError(t, decoded.UnmarshalBinary(mustMarshal(t, relay)))
+		duid, err := decoded.InnerClientId()
+		assert.NoError(t, err, "%d hops", hops+1)
+		assert.True(t, DuidEqual(clientId.Duid, duid), "%d hops", hops+1)
+	}
+
+	client.RemoveOptions(OptionCodeClientId)
+	_, err := relayMessage(0, client).InnerClientId()
+	assert.True(t, errors.Is(err, ErrMissingOption))
+
+	var deep Message = exampleSolicit()
+	for i := 0; i <= HopCountLimit+1; i++ {
+		deep = relayMessage(byte(i), deep)
+	}
+	_, err = deep.(*DhcpRelayMessage).InnerClientId()
+	assert.Equal(t, ErrHopCountExceeded, err)
+}
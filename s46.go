@@ -0,0 +1,260 @@
+package dhcpv6
+
+import (
+	"encoding/binary"
+	"net"
+)
+
+// Softwire (MAP-E, MAP-T and Lightweight 4over6) options
+//
+// https://tools.ietf.org/html/rfc7598
+
+// marshalContainer encodes an option that carries nothing but a list of
+// sub-options.
+func marshalContainer(code OptionCode, options []Option) ([]byte, error) {
+	data := make([]byte, 4, 64)
+	binary.BigEndian.PutUint16(data, uint16(code))
+	for i := range options {
+		optionData, err := options[i].MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		if len(data)+len(optionData) > 65539 { //65535+4
+			return nil, ErrWontFit
+		}
+		data = append(data, optionData...)
+	}
+	binary.BigEndian.PutUint16(data[2:], uint16(len(data)-4))
+	return data, nil
+}
+
+// unmarshalContainer decodes an option that carries nothing but a list of
+// sub-options.
+func unmarshalContainer(code OptionCode, data []byte) ([]Option, error) {
+	if len(data) < 4 {
+		return nil, ErrUnexpectedEOF
+	}
+	if binary.BigEndian.Uint16(data) != uint16(code) {
+		return nil, ErrInvalidType
+	}
+	olen := binary.BigEndian.Uint16(data[2:])
+	if len(data) < int(olen)+4 {
+		return nil, ErrUnexpectedEOF
+	}
+	return unmarshalSubOptions(data[4 : olen+4])
+}
+
+// unmarshalSubOptions decodes a list of options that must exactly fill data.
+// More than DefaultMaxOptions options result in ErrTooManyOptions.
+func unmarshalSubOptions(data []byte) ([]Option, error) {
+	options := make([]Option, 0)
+	for len(data) != 0 {
+		if len(data) < 4 {
+			return nil, ErrUnexpectedEOF
+		}
+		if len(options) == DefaultMaxOptions {
+			return nil, ErrTooManyOptions
+		}
+		nextSize := int(binary.BigEndian.Uint16(data[2:]))
+		if len(data) < nextSize+4 {
+			return nil, ErrUnexpectedEOF
+		}
+		option, err := UnmarshalBinaryOption(data[:nextSize+4])
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, option)
+		data = data[nextSize+4:]
+	}
+	return options, nil
+}
+
+// S46 Rule Option
+//
+// https://tools.ietf.org/html/rfc7598#section-4.1
+type S46RuleOption struct {
+	Flags          uint8
+	EaLen          uint8
+	Prefix4Len     uint8
+	Ipv4Prefix     net.IP
+	Prefix6Len     uint8
+	Ipv6Prefix     net.IP
+	S46RuleOptions []Option
+}
+
+const (
+	// S46RuleFlagF marks a rule as a Forwarding Mapping Rule
+	S46RuleFlagF = 0x01
+)
+
+func (o *S46RuleOption) Code() OptionCode {
+	return OptionCodeS46Rule
+}
+func (o *S46RuleOption) MarshalBinary() ([]byte, error) {
+	if o.Prefix4Len > 32 || o.Prefix6Len > 128 {
+		return nil, ErrInvalidData
+	}
+	ipv4 := o.Ipv4Prefix.To4()
+	if ipv4 == nil {
+		return nil, ErrInvalidData
+	}
+	if err := checkIpv6Address(o.Ipv6Prefix); err != nil {
+		return nil, err
+	}
+	prefixLen := (int(o.Prefix6Len) + 7) / 8
+	data := make([]byte, 12+prefixLen, 64)
+	binary.BigEndian.PutUint16(data, uint16(OptionCodeS46Rule))
+	data[4] = o.Flags
+	data[5] = o.EaLen
+	data[6] = o.Prefix4Len
+	copy(data[7:], ipv4)
+	data[11] = o.Prefix6Len
+	copy(data[12:], o.Ipv6Prefix[:prefixLen])
+	for i := range o.S46RuleOptions {
+		optionData, err := o.S46RuleOptions[i].MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		if len(data)+len(optionData) > 65539 { //65535+4
+			return nil, ErrWontFit
+		}
+		data = append(data, optionData...)
+	}
+	binary.BigEndian.PutUint16(data[2:], uint16(len(data)-4))
+	return data, nil
+}
+func (o *S46RuleOption) UnmarshalBinary(data []byte) error {
+	if len(data) < 12 {
+		return ErrUnexpectedEOF
+	}
+	if binary.BigEndian.Uint16(data) != uint16(OptionCodeS46Rule) {
+		return ErrInvalidType
+	}
+	olen := binary.BigEndian.Uint16(data[2:])
+	if olen < 8 {
+		return ErrInvalidData
+	}
+	if len(data) < int(olen)+4 {
+		return ErrUnexpectedEOF
+	}
+	if data[6] > 32 || data[11] > 128 {
+		return ErrInvalidData
+	}
+	prefixLen := (int(data[11]) + 7) / 8
+	if int(olen) < 8+prefixLen {
+		return ErrUnexpectedEOF
+	}
+	o.Flags = data[4]
+	o.EaLen = data[5]
+	o.Prefix4Len = data[6]
+	o.Ipv4Prefix = net.IPv4(data[7], data[8], data[9], data[10]).To4()
+	o.Prefix6Len = data[11]
+	o.Ipv6Prefix = make(net.IP, net.IPv6len)
+	copy(o.Ipv6Prefix, data[12:12+prefixLen])
+	options, err := unmarshalSubOptions(data[12+prefixLen : olen+4])
+	if err != nil {
+		return err
+	}
+	o.S46RuleOptions = options
+	return nil
+}
+
+// S46 Border Relay Option
+//
+// https://tools.ietf.org/html/rfc7598#section-4.2
+type S46BrOption struct {
+	BrAddress net.IP
+}
+
+func (o *S46BrOption) Code() OptionCode {
+	return OptionCodeS46Br
+}
+func (o *S46BrOption) MarshalBinary() ([]byte, error) {
+	if err := checkIpv6Address(o.BrAddress); err != nil {
+		return nil, err
+	}
+	data := make([]byte, 20)
+	binary.BigEndian.PutUint16(data, uint16(OptionCodeS46Br))
+	binary.BigEndian.PutUint16(data[2:], net.IPv6len)
+	copy(data[4:], o.BrAddress)
+	return data, nil
+}
+func (o *S46BrOption) UnmarshalBinary(data []byte) error {
+	if len(data) < 20 {
+		return ErrUnexpectedEOF
+	}
+	if binary.BigEndian.Uint16(data) != uint16(OptionCodeS46Br) {
+		return ErrInvalidType
+	}
+	if binary.BigEndian.Uint16(data[2:]) != net.IPv6len {
+		return ErrInvalidData
+	}
+	o.BrAddress = net.IP(cloneBytes(data[4:20]))
+	return nil
+}
+
+// S46 MAP-E Container Option
+//
+// https://tools.ietf.org/html/rfc7598#section-5.1
+type S46ContMapEOption struct {
+	S46Options []Option
+}
+
+func (o *S46ContMapEOption) Code() OptionCode {
+	return OptionCodeS46ContMapE
+}
+func (o *S46ContMapEOption) MarshalBinary() ([]byte, error) {
+	return marshalContainer(OptionCodeS46ContMapE, o.S46Options)
+}
+func (o *S46ContMapEOption) UnmarshalBinary(data []byte) error {
+	options, err := unmarshalContainer(OptionCodeS46ContMapE, data)
+	if err != nil {
+		return err
+	}
+	o.S46Options = options
+	return nil
+}
+
+// S46 MAP-T Container Option
+//
+// https://tools.ietf.org/html/rfc7598#section-5.2
+type S46ContMapTOption struct {
+	S46Options []Option
+}
+
+func (o *S46ContMapTOption) Code() OptionCode {
+	return OptionCodeS46ContMapT
+}
+func (o *S46ContMapTOption) MarshalBinary() ([]byte, error) {
+	return marshalContainer(OptionCodeS46ContMapT, o.S46Options)
+}
+func (o *S46ContMapTOption) UnmarshalBinary(data []byte) error {
+	options, err := unmarshalContainer(OptionCodeS46ContMapT, data)
+	if err != nil {
+		return err
+	}
+	o.S46Options = options
+	return nil
+}
+
+// S46 Lightweight 4over6 Container Option
+//
+// https://tools.ietf.org/html/rfc7598#section-5.3
+type S46ContLwOption struct {
+	S46Options []Option
+}
+
+func (o *S46ContLwOption) Code() OptionCode {
+	return OptionCodeS46ContLw
+}
+func (o *S46ContLwOption) MarshalBinary() ([]byte, error) {
+	return marshalContainer(OptionCodeS46ContLw, o.S46Options)
+}
+func (o *S46ContLwOption) UnmarshalBinary(data []byte) error {
+	options, err := unmarshalContainer(OptionCodeS46ContLw, data)
+	if err != nil {
+		return err
+	}
+	o.S46Options = options
+	return nil
+}
@@ -0,0 +1,68 @@
+package dhcpv6
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+func TestS46ContMapEOption(t *testing.T) {
+	o := &S46ContMapEOption{
+		S46Options: []Option{
+			&S46RuleOption{
+				Flags:          S46RuleFlagF,
+				EaLen:          16,
+				Prefix4Len:     24,
+				Ipv4Prefix:     net.IPv4(192, 0, 2, 0).To4(),
+				Prefix6Len:     40,
+				Ipv6Prefix:     net.ParseIP("2001:db8:ff00::"),
+				S46RuleOptions: []Option{},
+			},
+		},
+	}
+	data, err := o.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{
+		0x00, 0x5e, 0x00, 0x11,
+		0x00, 0x59, 0x00, 0x0d, 0x01, 0x10, 0x18, 0xc0, 0x00, 0x02, 0x00, 0x28, 0x20, 0x01, 0x0d, 0xb8, 0xff,
+	}, data)
+
+	option, err := UnmarshalBinaryOption(data)
+	assert.NoError(t, err)
+	assert.Equal(t, o, option)
+
+	_, err = UnmarshalBinaryOption([]byte{0x00, 0x5e, 0x00, 0x04, 0x00, 0x59, 0x00, 0x08})
+	assert.Equal(t, ErrUnexpectedEOF, err, "truncated rule")
+}
+
+func TestS46ContLwOption(t *testing.T) {
+	data := []byte{0x00, 0x60, 0x00, 0x00}
+	option, err := UnmarshalBinaryOption(data)
+	assert.NoError(t, err)
+	assert.Equal(t, &S46ContLwOption{S46Options: []Option{}}, option)
+	actual, err := option.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, data, actual)
+}
+
+func TestS46BrOption(t *testing.T) {
+	o := &S46ContLwOption{
+		S46Options: []Option{
+			&S46BrOption{BrAddress: net.ParseIP("2001:db8::1")},
+		},
+	}
+	data, err := o.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{
+		0x00, 0x60, 0x00, 0x14,
+		0x00, 0x5a, 0x00, 0x10, 0x20, 0x01, 0x0d, 0xb8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01,
+	}, data)
+	option, err := UnmarshalBinaryOption(data)
+	assert.NoError(t, err)
+	assert.Equal(t, o, option)
+
+	_, err = (&S46BrOption{BrAddress: net.IPv4(192, 0, 2, 1).To4()}).MarshalBinary()
+	assert.Equal(t, ErrInvalidIpv6Address, err)
+	err = new(S46BrOption).UnmarshalBinary(append([]byte{0x00, 0x5a, 0x00, 0x04}, make([]byte, 16)...))
+	assert.Equal(t, ErrInvalidData, err)
+}
@@ -0,0 +1,87 @@
+package dhcpv6
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// OptionSpan describes the location of a single option within a raw message.
+type OptionSpan struct {
+	Code OptionCode
+
+	// Offset of the option code from the start of the message.
+	Offset int
+
+	// Length of the option, including the 4-byte code and length header.
+	Length int
+}
+
+// ScanOptions will walk the top-level options of a raw client/server message
+// (starting after the 4-byte message header) without decoding them, returning
+// the position of each.
+func ScanOptions(data []byte) ([]OptionSpan, error) {
+	if len(data) < 4 {
+		return nil, ErrUnexpectedEOF
+	}
+	return scanOptions(data, 4)
+}
+
+func scanOptions(data []byte, pos int) ([]OptionSpan, error) {
+	spans := make([]OptionSpan, 0, 10)
+	for pos < len(data) {
+		if len(data)-pos < 4 {
+			return nil, ErrUnexpectedEOF
+		}
+		olen := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if len(data)-pos < olen+4 {
+			return nil, ErrUnexpectedEOF
+		}
+		spans = append(spans, OptionSpan{
+			Code:   OptionCode(binary.BigEndian.Uint16(data[pos:])),
+			Offset: pos,
+			Length: olen + 4,
+		})
+		pos += olen + 4
+	}
+	return spans, nil
+}
+
+// checkOptions walks the options of a message starting at pos like
+// scanOptions, but only checks that each fits within data, without recording
+// them. Trailing zero padding is skipped, as the decoder ignores it.
+func checkOptions(data []byte, pos int) error {
+	end := len(bytes.TrimRight(data, "\x00"))
+	for pos < end {
+		if len(data)-pos < 4 {
+			return ErrUnexpectedEOF
+		}
+		pos += 4 + int(binary.BigEndian.Uint16(data[pos+2:]))
+		if pos > len(data) {
+			return ErrUnexpectedEOF
+		}
+	}
+	return nil
+}
+
+// QuickValidate is a cheap pre-filter for raw packets: it checks that the
+// message type is known, that the header is complete and that the top-level
+// options are self-consistent, without decoding any of them or allocating. A
+// packet passing QuickValidate may still fail to decode, but any packet the
+// (lenient) decoder accepts passes, including one with trailing zero padding.
+func QuickValidate(data []byte) error {
+	if len(data) < 4 {
+		return ErrUnexpectedEOF
+	}
+	t := DhcpMessageType(data[0])
+	if !isKnownMessageType(t) {
+		return ErrInvalidType
+	}
+	headerLen := 4
+	if t == TypeRelayForward || t == TypeRelayReply {
+		headerLen = 34
+	}
+	if len(data) < headerLen {
+		return ErrUnexpectedEOF
+	}
+	return checkOptions(data, headerLen)
+}
@@ -0,0 +1,53 @@
+package dhcpv6
+
+import (
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestScanOptions(t *testing.T) {
+	data, _ := hex.DecodeString("01a0a7a2000e00000003000cafaaaca30000000000000000000600060017001800380001000e00020000ab11aca2a8afaea3a3af000800020000")
+	spans, err := ScanOptions(data)
+	assert.NoError(t, err)
+	assert.Equal(t, []OptionSpan{
+		{Code: OptionCodeRapidCommit, Offset: 4, Length: 4},
+		{Code: OptionCodeIaNa, Offset: 8, Length: 16},
+		{Code: OptionCodeOro, Offset: 24, Length: 10},
+		{Code: OptionCodeClientId, Offset: 34, Length: 18},
+		{Code: OptionCodeElapsedTime, Offset: 52, Length: 6},
+	}, spans)
+
+	_, err = ScanOptions(data[:len(data)-1])
+	assert.Equal(t, ErrUnexpectedEOF, err, "truncated option")
+	_, err = ScanOptions(data[:3])
+	assert.Equal(t, ErrUnexpectedEOF, err, "truncated header")
+	spans, err = ScanOptions(data[:4])
+	assert.NoError(t, err)
+	assert.Empty(t, spans)
+}
+
+func TestQuickValidate(t *testing.T) {
+	data, _ := hex.DecodeString("01a0a7a2000e00000003000cafaaaca30000000000000000000600060017001800380001000e00020000ab11aca2a8afaea3a3af000800020000")
+	assert.NoError(t, QuickValidate(data))
+	assert.Equal(t, ErrUnexpectedEOF, QuickValidate(data[:len(data)-1]), "truncated option")
+	assert.Equal(t, ErrUnexpectedEOF, QuickValidate(data[:3]), "truncated header")
+
+	invalid := append([]byte{0xff}, data[1:]...)
+	assert.Equal(t, ErrInvalidType, QuickValidate(invalid))
+
+	relay, err := goldenRelayMessages(t)["relay_forward"].MarshalBinary()
+	assert.NoError(t, err)
+	assert.NoError(t, QuickValidate(relay))
+	assert.Equal(t, ErrUnexpectedEOF, QuickValidate(relay[:20]), "truncated relay header")
+
+	//padding accepted by the decoder is accepted here too
+	padded := append(mustMarshal(t, exampleSolicit()), 0x00, 0x00, 0x00)
+	_, err = DecodeConfig{}.DecodeMessage(padded)
+	assert.NoError(t, err)
+	assert.NoError(t, QuickValidate(padded))
+	assert.Equal(t, ErrUnexpectedEOF, QuickValidate(append(padded, 0x01)))
+
+	allocs := testing.AllocsPerRun(10, func() { QuickValidate(data) })
+	assert.Equal(t, float64(0), allocs)
+}
@@ -0,0 +1,133 @@
+package dhcpv6
+
+import (
+	"errors"
+	"log"
+	"net"
+	"sync"
+)
+
+// Handler responds to a message received from src, returning the reply to
+// send back, or nil to send nothing.
+type Handler func(src net.Addr, msg *DhcpMessage) *DhcpMessage
+
+// Server is a minimal DHCPv6 server, passing each message received to a
+// Handler. Relay messages are not unwrapped, and are silently dropped.
+type Server struct {
+	// Interface, if set, is the interface on which the multicast groups of
+	// servers are joined. Otherwise the system chooses one.
+	Interface *net.Interface
+
+	// Logger is called with a description of each message that could not be
+	// decoded or replied to. If nil, log.Printf is used.
+	Logger func(format string, args ...interface{})
+
+	// started, if set, is called with the connection once the server is
+	// ready to receive on it.
+	started func(conn net.PacketConn)
+
+	mx     sync.Mutex
+	conn   net.PacketConn
+	closed bool
+}
+
+// ListenAndServe listens on the UDP address addr (":547" if empty), joins the
+// All_DHCP_Relay_Agents_and_Servers and All_DHCP_Servers multicast groups,
+// and serves each message received with handler until Close is called, at
+// which point nil is returned.
+//
+// Relay messages are dropped without being logged, as a server reached only
+// by clients on its links has no use for them. Other datagrams that do not
+// decode as a client/server message are logged and dropped, as are replies
+// that fail to be sent.
+func (s *Server) ListenAndServe(addr string, handler Handler) error {
+	if addr == "" {
+		addr = ":547"
+	}
+	udpAddr, err := net.ResolveUDPAddr("udp6", addr)
+	if err != nil {
+		return err
+	}
+	conn, err := net.ListenUDP("udp6", udpAddr)
+	if err != nil {
+		return err
+	}
+	if err := JoinDhcpMulticast(conn, s.Interface); err != nil {
+		conn.Close()
+		return err
+	}
+	if err := joinAllServers(conn, s.Interface); err != nil {
+		conn.Close()
+		return err
+	}
+	return s.serve(conn, handler)
+}
+
+// Close stops the server, closing its connection.
+func (s *Server) Close() error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.closed = true
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.Close()
+}
+
+func (s *Server) logf(format string, args ...interface{}) {
+	if s.Logger == nil {
+		log.Printf(format, args...)
+		return
+	}
+	s.Logger(format, args...)
+}
+
+// serve reads messages from conn until it is closed, passing each to handler
+// and sending back its reply.
+func (s *Server) serve(conn net.PacketConn, handler Handler) error {
+	s.mx.Lock()
+	if s.closed {
+		s.mx.Unlock()
+		conn.Close()
+		return nil
+	}
+	s.conn = conn
+	s.mx.Unlock()
+	if s.started != nil {
+		s.started(conn)
+	}
+
+	buf := make([]byte, DefaultMaxMessageSize)
+	for {
+		n, src, err := conn.ReadFrom(buf)
+		if err != nil {
+			s.mx.Lock()
+			closed := s.closed
+			s.mx.Unlock()
+			if closed && errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		if n > 0 && (DhcpMessageType(buf[0]) == TypeRelayForward || DhcpMessageType(buf[0]) == TypeRelayReply) {
+			continue
+		}
+		msg := new(DhcpMessage)
+		if err := msg.UnmarshalBinary(buf[:n]); err != nil {
+			s.logf("Dropped message from %v: %v", src, err)
+			continue
+		}
+		reply := handler(src, msg)
+		if reply == nil {
+			continue
+		}
+		data, err := reply.MarshalBinary()
+		if err != nil {
+			s.logf("Dropped %v to %v: %v", reply.MsgType, src, err)
+			continue
+		}
+		if _, err := conn.WriteTo(data, src); err != nil {
+			s.logf("Failed to send %v to %v: %v", reply.MsgType, src, err)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package dhcpv6
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestServer_serve(t *testing.T) {
+	conn, err := net.ListenPacket("udp6", "[::1]:0")
+	assert.NoError(t, err)
+	client, err := net.ListenPacket("udp6", "[::1]:0")
+	assert.NoError(t, err)
+	defer client.Close()
+
+	var mx sync.Mutex
+	var logged []string
+	s := &Server{Logger: func(format string, args ...interface{}) {
+		mx.Lock()
+		logged = append(logged, fmt.Sprintf(format, args...))
+		mx.Unlock()
+	}}
+	handler := func(src net.Addr, msg *DhcpMessage) *DhcpMessage {
+		if msg.MsgType != TypeSolicit {
+			return nil
+		}
+		return &DhcpMessage{MsgType: TypeAdvertise, TransactionId: msg.TransactionId}
+	}
+	done := make(chan error)
+	go func() { done <- s.serve(conn, handler) }()
+
+	send := func(data []byte) {
+		_, err := client.WriteTo(data, conn.LocalAddr())
+		assert.NoError(t, err)
+	}
+	send([]byte{byte(TypeRelayForward), 0x00})
+	send([]byte{byte(TypeSolicit), 0x00})
+	send(mustMarshal(t, &DhcpMessage{MsgType: TypeInformationRequest}))
+	send(mustMarshal(t, exampleSolicit()))
+
+	buf := make([]byte, 1500)
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := client.ReadFrom(buf)
+	assert.NoError(t, err)
+	reply := new(DhcpMessage)
+	assert.NoError(t, reply.UnmarshalBinary(buf[:n]))
+	assert.Equal(t, TypeAdvertise, reply.MsgType, "the dropped messages get no reply")
+	assert.Equal(t, exampleSolicit().TransactionId, reply.TransactionId)
+
+	assert.NoError(t, s.Close())
+	assert.NoError(t, <-done)
+	mx.Lock()
+	if assert.Len(t, logged, 1, "relay messages are dropped silently") {
+		assert.Contains(t, logged[0], "Dropped message")
+	}
+	mx.Unlock()
+}
+
+func TestServer_Close(t *testing.T) {
+	conn, err := net.ListenPacket("udp6", "[::1]:0")
+	assert.NoError(t, err)
+	s := new(Server)
+	assert.NoError(t, s.Close())
+	assert.NoError(t, s.serve(conn, nil), "closed before serving")
+}
+
+// multicastInterface returns an interface that is up and supports multicast.
+func multicastInterface(t *testing.T) *net.Interface {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range ifaces {
+		if ifaces[i].Flags&net.FlagUp != 0 && ifaces[i].Flags&net.FlagMulticast != 0 {
+			return &ifaces[i]
+		}
+	}
+	t.Skip("no multicast capable interface")
+	return nil
+}
+
+func TestServer_ListenAndServe(t *testing.T) {
+	iface := multicastInterface(t)
+	started := make(chan net.PacketConn, 1)
+	s := &Server{Interface: iface, started: func(conn net.PacketConn) { started <- conn }}
+	handler := func(src net.Addr, msg *DhcpMessage) *DhcpMessage {
+		return &DhcpMessage{MsgType: TypeAdvertise, TransactionId: msg.TransactionId}
+	}
+	done := make(chan error, 1)
+	go func() { done <- s.ListenAndServe("[::]:0", handler) }()
+
+	var conn net.PacketConn
+	select {
+	case conn = <-started:
+	case err := <-done:
+		t.Skip("multicast unavailable:", err)
+	}
+
+	//a Solicit sent to the All_DHCP_Relay_Agents_and_Servers group is answered
+	client, err := net.ListenPacket("udp6", "[::]:0")
+	assert.NoError(t, err)
+	defer client.Close()
+	group := AllRelayAgentsAndServersAddr()
+	group.Port = conn.LocalAddr().(*net.UDPAddr).Port
+	group.Zone = iface.Name
+	_, err = client.WriteTo(mustMarshal(t, exampleSolicit()), group)
+	assert.NoError(t, err)
+
+	buf := make([]byte, 1500)
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := client.ReadFrom(buf)
+	if assert.NoError(t, err) {
+		reply := new(DhcpMessage)
+		assert.NoError(t, reply.UnmarshalBinary(buf[:n]))
+		assert.Equal(t, exampleSolicit().TransactionId, reply.TransactionId)
+	}
+
+	assert.NoError(t, s.Close())
+	assert.NoError(t, <-done)
+}
@@ -0,0 +1,158 @@
+package dhcpv6
+
+import (
+	"fmt"
+)
+
+// ClientState is the state of a ClientSession.
+type ClientState int
+
+const (
+	StateInit ClientState = iota
+	StateSoliciting
+	StateRequesting
+	StateBound
+	StateRenewing
+	StateRebinding
+)
+
+var clientStateNames = map[ClientState]string{
+	StateInit:       "INIT",
+	StateSoliciting: "SOLICITING",
+	StateRequesting: "REQUESTING",
+	StateBound:      "BOUND",
+	StateRenewing:   "RENEWING",
+	StateRebinding:  "REBINDING",
+}
+
+func (s ClientState) String() string {
+	if name, ok := clientStateNames[s]; ok {
+		return name
+	}
+	return unknownName(int(s))
+}
+
+// ClientSession drives the RFC 3315 client state machine for a set of IAs,
+// building each message with the New* message builders:
+//
+//	INIT --Solicit--> SOLICITING --Advertise--> REQUESTING --Request/Reply--> BOUND
+//	BOUND --Renew--> RENEWING --Reply--> BOUND
+//	RENEWING --Timeout/Rebind--> REBINDING --Reply--> BOUND
+//
+// NextMessage returns the message to send in the current state, and
+// HandleReply processes the server's response to it. Timers (retransmission,
+// T1, T2 and lifetimes) are left to the caller: NextMessage is called when T1
+// passes to start renewing, and Timeout when an exchange has failed.
+//
+// A ClientSession is not safe for concurrent use.
+type ClientSession struct {
+	ClientDuid Duid
+
+	// IAs are the IA options (IA_NA, IA_TA or IA_PD) requested by the
+	// Solicit. Once bound, the IAs assigned by the server are used instead.
+	IAs []Option
+
+	state   ClientState
+	pending *DhcpMessage
+	server  Duid
+	reply   *DhcpMessage
+}
+
+// State returns the current state of the session.
+func (s *ClientSession) State() ClientState {
+	return s.state
+}
+
+// Reply returns the Reply that last bound the session, from which the leases
+// (see LeaseEntries) and configuration (see NetworkConfig) may be read. It is
+// nil until the session is first bound.
+func (s *ClientSession) Reply() *DhcpMessage {
+	return s.reply
+}
+
+// NextMessage returns the message to send in the current state. Until a
+// response is handled, the same message is returned again, so that
+// retransmissions keep the transaction id.
+//
+// A Solicit is sent in StateInit, a Request in StateRequesting and a Rebind in
+// StateRebinding. In StateBound a Renew is sent, moving to StateRenewing.
+func (s *ClientSession) NextMessage() (*DhcpMessage, error) {
+	if s.pending != nil {
+		return s.pending, nil
+	}
+	var msg *DhcpMessage
+	var err error
+	next := s.state
+	switch s.state {
+	case StateInit:
+		msg, err = NewSolicit(s.ClientDuid, s.IAs...)
+		next = StateSoliciting
+	case StateRequesting:
+		msg, err = NewRequest(s.ClientDuid, s.server, s.IAs...)
+	case StateBound:
+		msg, err = NewRenew(s.ClientDuid, s.server, s.IAs...)
+		next = StateRenewing
+	case StateRebinding:
+		msg, err = newClientMessage(TypeRebind, s.ClientDuid, nil, s.IAs)
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrSessionState, s.state)
+	}
+	//the state only changes once there is a message to send in it
+	if err != nil {
+		return nil, err
+	}
+	s.state = next
+	s.pending = msg
+	return msg, nil
+}
+
+// HandleReply processes the server's response to the last message returned
+// by NextMessage, advancing the state on success. Messages that are not a
+// response to it (see ValidateReplyToStrict) are rejected without changing
+// the state, as are responses with an unsuccessful top-level status code.
+func (s *ClientSession) HandleReply(msg *DhcpMessage) error {
+	if s.pending == nil {
+		return fmt.Errorf("%w: %v", ErrSessionState, s.state)
+	}
+	if err := msg.ValidateReplyToStrict(s.pending); err != nil {
+		return err
+	}
+	if status, ok := msg.StatusCode(); ok && status.StatusCode != Success {
+		return fmt.Errorf("%w: %v %q", ErrStatusCode, status.StatusCode, status.StatusMessage)
+	}
+	serverId, ok := msg.ServerId()
+	if !ok {
+		return fmt.Errorf("%w: %v in %v", ErrMissingOption, OptionCodeServerId, msg.MsgType)
+	}
+	s.server = serverId.Duid
+	s.pending = nil
+	if msg.MsgType == TypeAdvertise {
+		s.state = StateRequesting
+		return nil
+	}
+	s.reply = msg
+	var ias []Option
+	for _, o := range msg.Options {
+		switch o.(type) {
+		case *IaNaOption, *IaTaOption, *IaPdOption:
+			ias = append(ias, o)
+		}
+	}
+	s.IAs = ias
+	s.state = StateBound
+	return nil
+}
+
+// Timeout is called when the exchange in progress has failed: when T2 passes
+// while renewing, the session starts rebinding (with any server), and in any
+// other state, the session starts over from StateInit.
+func (s *ClientSession) Timeout() {
+	s.pending = nil
+	if s.state == StateRenewing {
+		s.state = StateRebinding
+		return
+	}
+	s.state = StateInit
+	s.server = nil
+	s.reply = nil
+}
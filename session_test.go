@@ -0,0 +1,113 @@
+package dhcpv6
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+// serverResponse builds the response of the placeholder server to req.
+func serverResponse(msgType DhcpMessageType, req *DhcpMessage, options ...Option) *DhcpMessage {
+	return &DhcpMessage{
+		MsgType:       msgType,
+		TransactionId: req.TransactionId,
+		Options: append([]Option{
+			&ClientIdOption{Duid: placeholderClientDuid()},
+			&ServerIdOption{Duid: placeholderServerDuid()},
+		}, options...),
+	}
+}
+
+func TestClientSession(t *testing.T) {
+	s := &ClientSession{
+		ClientDuid: placeholderClientDuid(),
+		IAs:        []Option{&IaNaOption{IAID: [4]byte{0, 0, 0, 1}}},
+	}
+	assert.Equal(t, StateInit, s.State())
+
+	solicit, err := s.NextMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, TypeSolicit, solicit.MsgType)
+	assert.Equal(t, StateSoliciting, s.State())
+	again, err := s.NextMessage()
+	assert.NoError(t, err)
+	assert.Same(t, solicit, again, "retransmissions reuse the message")
+
+	stray := serverResponse(TypeAdvertise, solicit)
+	stray.TransactionId[0]++
+	assert.Equal(t, ErrTransactionIdMismatch, s.HandleReply(stray))
+	assert.NoError(t, s.HandleReply(serverResponse(TypeAdvertise, solicit)))
+	assert.Equal(t, StateRequesting, s.State())
+
+	request, err := s.NextMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, TypeRequest, request.MsgType)
+	serverId, ok := request.ServerId()
+	if assert.True(t, ok) {
+		assert.Equal(t, placeholderServerDuid(), serverId.Duid)
+	}
+
+	ia := &IaNaOption{
+		IAID: [4]byte{0, 0, 0, 1},
+		T1:   1800,
+		T2:   2880,
+		IaNaOptions: []Option{
+			&IaAddrOption{Ipv6Address: net.ParseIP("2001:db8::1"), PreferredLifetime: 3600, ValidLifetime: 7200},
+		},
+	}
+	reply := serverResponse(TypeReply, request, ia)
+	assert.NoError(t, s.HandleReply(reply))
+	assert.Equal(t, StateBound, s.State())
+	assert.Same(t, reply, s.Reply())
+	assert.Len(t, s.Reply().LeaseEntries(), 1)
+
+	renew, err := s.NextMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, TypeRenew, renew.MsgType)
+	assert.Equal(t, StateRenewing, s.State())
+	assert.Equal(t, []*IaNaOption{ia}, renew.IaNa(), "renews the assigned IA")
+
+	s.Timeout()
+	assert.Equal(t, StateRebinding, s.State())
+	rebind, err := s.NextMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, TypeRebind, rebind.MsgType)
+	_, ok = rebind.ServerId()
+	assert.False(t, ok, "a Rebind is sent to any server")
+
+	failed := serverResponse(TypeReply, rebind, &StatusCodeOption{StatusCode: NoBinding})
+	assert.True(t, errors.Is(s.HandleReply(failed), ErrStatusCode))
+	assert.Equal(t, StateRebinding, s.State())
+	assert.NoError(t, s.HandleReply(serverResponse(TypeReply, rebind, ia)))
+	assert.Equal(t, StateBound, s.State())
+
+	s.Timeout()
+	assert.Equal(t, StateInit, s.State())
+	assert.Nil(t, s.Reply())
+}
+
+func TestClientSession_HandleReply_state(t *testing.T) {
+	s := &ClientSession{ClientDuid: placeholderClientDuid()}
+	assert.True(t, errors.Is(s.HandleReply(&DhcpMessage{MsgType: TypeReply}), ErrSessionState), "nothing was sent")
+	assert.Equal(t, "REBINDING", StateRebinding.String())
+}
+
+func TestClientSession_NextMessage_error(t *testing.T) {
+	s := new(ClientSession)
+	_, err := s.NextMessage()
+	assert.Equal(t, ErrInvalidData, err, "no client DUID")
+	assert.Equal(t, StateInit, s.State(), "unchanged on failure")
+
+	s.ClientDuid = placeholderClientDuid()
+	msg, err := s.NextMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, TypeSolicit, msg.MsgType)
+	assert.Equal(t, StateSoliciting, s.State())
+
+	//a bound session without a server DUID can not renew
+	s = &ClientSession{ClientDuid: placeholderClientDuid(), state: StateBound}
+	_, err = s.NextMessage()
+	assert.Equal(t, ErrInvalidData, err)
+	assert.Equal(t, StateBound, s.State())
+}
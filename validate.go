@@ -0,0 +1,236 @@
+package dhcpv6
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// occurrence is how often an option may appear in a particular message type.
+type occurrence int
+
+const (
+	exactlyOnce occurrence = iota + 1
+	atMostOnce
+	never
+)
+
+// messageOptionRules lists, for each message type, the top-level options
+// that are required or forbidden by RFC 3315 sections 15 through 18. Options
+// not listed for a type (and types not listed at all) are unrestricted.
+var messageOptionRules = map[DhcpMessageType]map[OptionCode]occurrence{
+	TypeSolicit: {
+		OptionCodeClientId: exactlyOnce,
+		OptionCodeServerId: never,
+	},
+	TypeAdvertise: {
+		OptionCodeClientId:    exactlyOnce,
+		OptionCodeServerId:    exactlyOnce,
+		OptionCodeRapidCommit: never,
+	},
+	TypeRequest: {
+		OptionCodeClientId:    exactlyOnce,
+		OptionCodeServerId:    exactlyOnce,
+		OptionCodeRapidCommit: never,
+	},
+	TypeConfirm: {
+		OptionCodeClientId:    exactlyOnce,
+		OptionCodeServerId:    never,
+		OptionCodeRapidCommit: never,
+	},
+	TypeRenew: {
+		OptionCodeClientId:    exactlyOnce,
+		OptionCodeServerId:    exactlyOnce,
+		OptionCodeRapidCommit: never,
+	},
+	TypeRebind: {
+		OptionCodeClientId:    exactlyOnce,
+		OptionCodeServerId:    never,
+		OptionCodeRapidCommit: never,
+	},
+	TypeReply: {
+		OptionCodeClientId: atMostOnce,
+		OptionCodeServerId: exactlyOnce,
+	},
+	TypeRelease: {
+		OptionCodeClientId:    exactlyOnce,
+		OptionCodeServerId:    exactlyOnce,
+		OptionCodeRapidCommit: never,
+	},
+	TypeDecline: {
+		OptionCodeClientId:    exactlyOnce,
+		OptionCodeServerId:    exactlyOnce,
+		OptionCodeRapidCommit: never,
+	},
+	TypeReconfigure: {
+		OptionCodeClientId:    exactlyOnce,
+		OptionCodeServerId:    exactlyOnce,
+		OptionCodeReconfMsg:   exactlyOnce,
+		OptionCodeRapidCommit: never,
+	},
+	TypeInformationRequest: {
+		OptionCodeClientId:    atMostOnce,
+		OptionCodeServerId:    atMostOnce,
+		OptionCodeRapidCommit: never,
+	},
+}
+
+// checkOptionRules enforces messageOptionRules, naming the offending option
+// in the returned error.
+func (d *DhcpMessage) checkOptionRules() error {
+	rules := messageOptionRules[d.MsgType]
+	counts := make(map[OptionCode]int, len(d.Options))
+	for _, o := range d.Options {
+		counts[o.Code()]++
+	}
+	codes := make([]OptionCode, 0, len(rules))
+	for code := range rules {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+	for _, code := range codes {
+		n := counts[code]
+		switch rules[code] {
+		case exactlyOnce:
+			if n == 0 {
+				return fmt.Errorf("%w: %v in %v", ErrMissingOption, code, d.MsgType)
+			}
+			fallthrough
+		case atMostOnce:
+			if n > 1 {
+				return fmt.Errorf("%w: %v in %v", ErrDuplicateOption, code, d.MsgType)
+			}
+		case never:
+			if n > 0 {
+				return fmt.Errorf("%w: %v in %v", ErrForbiddenOption, code, d.MsgType)
+			}
+		}
+	}
+	return nil
+}
+
+// singletonOptions may appear at most once among the top-level options of
+// any message.
+var singletonOptions = []OptionCode{
+	OptionCodeClientId,
+	OptionCodeServerId,
+	OptionCodeOro,
+	OptionCodePreference,
+	OptionCodeElapsedTime,
+	OptionCodeUnicast,
+	OptionCodeRapidCommit,
+	OptionCodeReconfMsg,
+	OptionCodeReconfAccept,
+	OptionCodeInformationRefreshTime,
+}
+
+// HasDuplicateSingletons reports whether an option that may appear at most
+// once (such as the Client Identifier, Server Identifier, Preference or
+// Elapsed Time option) appears more than once, returning the first such code.
+// Receivers may disagree on which copy wins, so such messages are ambiguous.
+func (d *DhcpMessage) HasDuplicateSingletons() (OptionCode, bool) {
+	counts := make(map[OptionCode]int, len(d.Options))
+	for _, o := range d.Options {
+		counts[o.Code()]++
+	}
+	for _, code := range singletonOptions {
+		if counts[code] > 1 {
+			return code, true
+		}
+	}
+	return 0, false
+}
+
+// Validate checks the message against the rules of the RFCs that can not be
+// enforced when decoding a single option, including the options required or
+// forbidden for each message type (see messageOptionRules).
+func (d *DhcpMessage) Validate() error {
+	if code, ok := d.HasDuplicateSingletons(); ok {
+		return fmt.Errorf("%w: %v", ErrDuplicateOption, code)
+	}
+	if err := d.checkOptionRules(); err != nil {
+		return err
+	}
+	if ids := d.DuplicateIaids(); len(ids) > 0 {
+		return fmt.Errorf("%w: %08x", ErrDuplicateIaid, ids[0])
+	}
+	for _, o := range d.Options {
+		if oro, ok := o.(*OroOption); ok {
+			if code, ok := oro.unrequestable(); ok {
+				return fmt.Errorf("%w: %d", ErrInvalidOptionRequest, code)
+			}
+		}
+	}
+	return nil
+}
+
+// unrequestable returns the first code requested by the option that can never
+// validly be requested: the Option Request option itself and the Client and
+// Server Identifier options.
+func (o *OroOption) unrequestable() (OptionCode, bool) {
+	for _, code := range o.RequestedOptionCodes {
+		switch OptionCode(code) {
+		case OptionCodeOro, OptionCodeClientId, OptionCodeServerId:
+			return OptionCode(code), true
+		}
+	}
+	return 0, false
+}
+
+// DuplicateIaids returns every IAID that is used by more than one IA of the
+// same type (IA_NA, IA_TA or IA_PD). A server must never send such a message.
+func (d *DhcpMessage) DuplicateIaids() []uint32 {
+	var dups []uint32
+	seen := make(map[OptionCode]map[uint32]int)
+	for _, o := range d.Options {
+		var iaid [4]byte
+		switch v := o.(type) {
+		case *IaNaOption:
+			iaid = v.IAID
+		case *IaTaOption:
+			iaid = v.IAID
+		case *IaPdOption:
+			iaid = v.IAID
+		default:
+			continue
+		}
+		if seen[o.Code()] == nil {
+			seen[o.Code()] = make(map[uint32]int)
+		}
+		id := binary.BigEndian.Uint32(iaid[:])
+		seen[o.Code()][id]++
+		if seen[o.Code()][id] == 2 {
+			dups = append(dups, id)
+		}
+	}
+	return dups
+}
+
+// OptionOrderWarnings describes orderings of the top-level options that, while
+// permitted by the RFCs, are known to trip up some implementations:
+//
+//   - a Client Identifier option that is not the first option
+//   - an Elapsed Time option following an IA (IA_NA, IA_TA or IA_PD) option
+//
+// It is intended for diagnostics only; such messages are still valid.
+func (d *DhcpMessage) OptionOrderWarnings() []string {
+	var warnings []string
+	firstIa := -1
+	for i, o := range d.Options {
+		switch o.Code() {
+		case OptionCodeClientId:
+			if i != 0 {
+				warnings = append(warnings, fmt.Sprintf("Client Identifier is option %d rather than the first", i+1))
+			}
+		case OptionCodeElapsedTime:
+			if firstIa >= 0 {
+				warnings = append(warnings, fmt.Sprintf("Elapsed Time (option %d) follows an IA option (option %d)", i+1, firstIa+1))
+			}
+		case OptionCodeIaNa, OptionCodeIaTa, OptionCodeIaPd:
+			if firstIa < 0 {
+				firstIa = i
+			}
+		}
+	}
+	return warnings
+}
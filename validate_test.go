@@ -0,0 +1,123 @@
+package dhcpv6
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDhcpMessage_DuplicateIaids(t *testing.T) {
+	d := &DhcpMessage{
+		MsgType: TypeReply,
+		Options: []Option{
+			&ServerIdOption{Duid: placeholderServerDuid()},
+			&IaNaOption{IAID: [4]byte{0, 0, 0, 1}},
+			&IaNaOption{IAID: [4]byte{0, 0, 0, 2}},
+			&IaTaOption{IAID: [4]byte{0, 0, 0, 1}},
+			&IaPdOption{IAID: [4]byte{0, 0, 0, 1}},
+		},
+	}
+	assert.Empty(t, d.DuplicateIaids(), "IAIDs only need to be unique per IA type")
+	assert.NoError(t, d.Validate())
+
+	d.Options = append(d.Options, &IaNaOption{IAID: [4]byte{0, 0, 0, 2}}, &IaNaOption{IAID: [4]byte{0, 0, 0, 2}})
+	assert.Equal(t, []uint32{2}, d.DuplicateIaids())
+	d.Options = append(d.Options, &IaPdOption{IAID: [4]byte{0, 0, 0, 1}})
+	assert.Equal(t, []uint32{2, 1}, d.DuplicateIaids())
+	assert.True(t, errors.Is(d.Validate(), ErrDuplicateIaid))
+
+	data, err := d.MarshalBinary()
+	assert.NoError(t, err)
+	_, err = DecodeConfig{}.DecodeMessage(data)
+	assert.NoError(t, err)
+	_, err = DecodeConfig{Strict: true}.DecodeMessage(data)
+	assert.True(t, errors.Is(err, ErrDuplicateIaid))
+}
+
+func TestDhcpMessage_Validate_optionRequest(t *testing.T) {
+	d, err := MinimalMessage(TypeSolicit)
+	assert.NoError(t, err)
+	d.Options = append(d.Options, &OroOption{RequestedOptionCodes: []uint16{23, 24}})
+	assert.NoError(t, d.Validate())
+
+	for _, code := range []OptionCode{OptionCodeOro, OptionCodeClientId, OptionCodeServerId} {
+		d.Options[2] = &OroOption{RequestedOptionCodes: []uint16{23, uint16(code)}}
+		assert.True(t, errors.Is(d.Validate(), ErrInvalidOptionRequest), "option %d", code)
+	}
+
+	//self-referential ORO
+	d.Options[2] = &OroOption{RequestedOptionCodes: []uint16{uint16(OptionCodeOro)}}
+	data, err := d.MarshalBinary()
+	assert.NoError(t, err)
+	_, err = DecodeConfig{}.DecodeMessage(data)
+	assert.NoError(t, err, "lenient mode is permissive")
+	_, err = DecodeConfig{Strict: true}.DecodeMessage(data)
+	assert.True(t, errors.Is(err, ErrInvalidOptionRequest))
+}
+
+func TestDhcpMessage_OptionOrderWarnings(t *testing.T) {
+	d, err := MinimalMessage(TypeConfirm)
+	assert.NoError(t, err)
+	assert.Empty(t, d.OptionOrderWarnings())
+
+	//IA_NA, Elapsed Time, Client Id
+	d.Options[0], d.Options[2] = d.Options[2], d.Options[0]
+	assert.Equal(t, []string{
+		"Elapsed Time (option 2) follows an IA option (option 1)",
+		"Client Identifier is option 3 rather than the first",
+	}, d.OptionOrderWarnings())
+	assert.NoError(t, d.Validate(), "warnings are advisory only")
+}
+
+func TestDhcpMessage_Validate_optionRules(t *testing.T) {
+	for msgType := range messageOptionRules {
+		d, err := MinimalMessage(msgType)
+		assert.NoError(t, err)
+		assert.NoError(t, d.Validate(), "%v", msgType)
+	}
+
+	d, err := MinimalMessage(TypeSolicit)
+	assert.NoError(t, err)
+	d.Options = append(d.Options, &ServerIdOption{Duid: placeholderServerDuid()})
+	err = d.Validate()
+	assert.True(t, errors.Is(err, ErrForbiddenOption))
+	assert.EqualError(t, err, "Message carries an option not permitted for its type: SERVERID in SOLICIT")
+
+	d, err = MinimalMessage(TypeSolicit)
+	assert.NoError(t, err)
+	d.Options = append(d.Options, &ClientIdOption{Duid: placeholderServerDuid()})
+	assert.True(t, errors.Is(d.Validate(), ErrDuplicateOption), "two Client Identifiers")
+
+	d, err = MinimalMessage(TypeReply)
+	assert.NoError(t, err)
+	d.Options = d.Options[:1]
+	assert.EqualError(t, d.Validate(), "Message is missing a required option: SERVERID in REPLY")
+
+	d.Options = append(d.Options, &ServerIdOption{Duid: placeholderServerDuid()}, &RapidCommitOption{})
+	assert.NoError(t, d.Validate(), "Rapid Commit is permitted in a Reply")
+	d.MsgType = TypeAdvertise
+	assert.True(t, errors.Is(d.Validate(), ErrForbiddenOption), "but not in an Advertise")
+
+	d.MsgType = TypeLeasequeryReply
+	assert.NoError(t, d.Validate(), "types without rules are unrestricted")
+}
+
+func TestDhcpMessage_HasDuplicateSingletons(t *testing.T) {
+	d, err := MinimalMessage(TypeRequest)
+	assert.NoError(t, err)
+	_, ok := d.HasDuplicateSingletons()
+	assert.False(t, ok)
+
+	d.Options = append(d.Options, &ClientIdOption{Duid: placeholderServerDuid()})
+	code, ok := d.HasDuplicateSingletons()
+	assert.True(t, ok)
+	assert.Equal(t, OptionCodeClientId, code)
+	assert.EqualError(t, d.Validate(), "Option may appear only once in a message: CLIENTID")
+
+	data, err := d.MarshalBinary()
+	assert.NoError(t, err)
+	_, err = DecodeConfig{}.DecodeMessage(data)
+	assert.NoError(t, err, "lenient mode is permissive")
+	_, err = DecodeConfig{Strict: true}.DecodeMessage(data)
+	assert.True(t, errors.Is(err, ErrDuplicateOption))
+}